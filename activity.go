@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// markActivity records a message added to a buffer that is not in view
+func (c *channelData) markActivity(msg Message) {
+	level := levelActivity
+	switch {
+	case msg.Kind == KindOwn || msg.Kind == KindSwitch || msg.Kind == KindSeparator:
+		return
+	case msg.Highlight:
+		level = levelHighlight
+	case msg.Kind == KindChat || msg.Kind == KindAction || msg.Kind == KindNotice:
+		level = levelMessage
+		if c.isQuery {
+			level = levelHighlight
+		}
+	}
+
+	if level >= levelMessage {
+		c.unread++
+	}
+	if level == levelHighlight {
+		c.highlights++
+	}
+	if level > c.level {
+		c.level = level
+	}
+}
+
+// markRead clears the activity once the buffer is viewed
+func (c *channelData) markRead() {
+	c.level = levelNone
+	c.unread = 0
+	c.highlights = 0
+}
+
+// activityBar lists the sidebar numbers of buffers with activity, like
+// irssi's Act: bar; highlights stand out, events alone are dimmed
+func (m model) activityBar() string {
+	var entries []string
+	for i, key := range m.getOpenBuffers() {
+		channel := m.channels[key]
+		if key == m.currentBuffer || channel.level == levelNone {
+			continue
+		}
+		number := fmt.Sprint(i + 1)
+		switch channel.level {
+		case levelHighlight:
+			entries = append(entries, actHighlightStyle.Render(number))
+		case levelMessage:
+			entries = append(entries, actMessageStyle.Render(number))
+		default:
+			entries = append(entries, actActivityStyle.Render(number))
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	// Plain text first so the header style still applies to it; the styled
+	// entries carry the header background themselves
+	return " - Act: " + strings.Join(entries, actActivityStyle.Render(","))
+}
+
+// nextActive finds the buffer to jump to with Alt+A: the most important
+// activity first, then the first in sidebar order
+func (m *model) nextActive() (string, bool) {
+	best, bestLevel := "", levelNone
+	for _, key := range m.getOpenBuffers() {
+		if level := m.channels[key].level; key != m.currentBuffer && level > bestLevel {
+			best, bestLevel = key, level
+		}
+	}
+	return best, best != ""
+}
+
+// activityBadges renders a buffer's unread and highlight counts for the sidebar
+func activityBadges(channel *channelData) string {
+	var badges string
+	if channel.highlights > 0 {
+		badges += " " + sidebarHighlightBadgeStyle.Render(fmt.Sprintf("%d!", channel.highlights))
+	}
+	if channel.unread > 0 {
+		badges += " " + sidebarUnreadBadgeStyle.Render(fmt.Sprint(channel.unread))
+	}
+	return badges
+}
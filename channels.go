@@ -3,32 +3,199 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
 )
 
-func (m *model) addChannel(channelName string) {
-	m.logger.Debug("Adding channel: %s", channelName)
-	if _, exists := m.channels[channelName]; !exists {
-		m.channels[channelName] = &channelData{
-			name:     channelName,
-			messages: []string{},
-			active:   false,
-			joined:   false,
-		}
-		m.channelOrder = append(m.channelOrder, channelName)
-		m.logger.Debug("Channel %s added successfully", channelName)
-	} else {
+// autoRejoinDelay gives ops a moment before we come back after a kick
+const autoRejoinDelay = 3 * time.Second
+
+type autoRejoinMsg struct{ network, channel string }
+
+// autoRejoin schedules a rejoin of a channel we were kicked from
+func autoRejoin(network, channel string) tea.Cmd {
+	return tea.Tick(autoRejoinDelay, func(time.Time) tea.Msg {
+		return autoRejoinMsg{network: network, channel: channel}
+	})
+}
+
+// bufferKey identifies a buffer across networks. Names are folded to lower
+// case since IRC channel names and nicks are case-insensitive.
+func bufferKey(network, name string) string {
+	return network + "/" + strings.ToLower(name)
+}
+
+func (m *model) addChannel(network, channelName string) *channelData {
+	m.logger.Debug("Adding channel: %s on %s", channelName, network)
+	key := bufferKey(network, channelName)
+	if channel, exists := m.channels[key]; exists {
 		m.logger.Debug("Channel %s already exists", channelName)
+		return channel
+	}
+	channel := &channelData{
+		network:  network,
+		name:     channelName,
+		messages: newMessageRing(m.config.UI.ScrollbackLines),
+		active:   false,
+		joined:   false,
+	}
+	m.channels[key] = channel
+	m.channelOrder = append(m.channelOrder, key)
+	m.replayChatLog(channel)
+	m.logger.Debug("Channel %s added successfully", channelName)
+	return channel
+}
+
+// key returns the buffer's key in model.channels
+func (c *channelData) key() string {
+	return bufferKey(c.network, c.name)
+}
+
+// isChannelName reports whether a message target is a channel rather than a nick
+func isChannelName(name string) bool {
+	return name != "" && strings.ContainsRune("#&+!", rune(name[0]))
+}
+
+// currentTarget is the name of the buffer in view, empty when there is none
+func (m *model) currentTarget() string {
+	if channel, exists := m.channels[m.currentBuffer]; exists {
+		return channel.name
+	}
+	return ""
+}
+
+// findBuffer looks up a buffer on a network, case-insensitively
+func (m *model) findBuffer(network, name string) (*channelData, bool) {
+	channel, exists := m.channels[bufferKey(network, name)]
+	return channel, exists
+}
+
+// resolveBuffer finds the buffer a user typed. "network/name" picks a network
+// explicitly; a bare name is looked up on the current network first.
+func (m *model) resolveBuffer(name string) (*channelData, bool) {
+	if network, bufferName, ok := strings.Cut(name, "/"); ok && m.network(network) != nil {
+		return m.findBuffer(network, bufferName)
+	}
+	if net := m.currentNetwork(); net != nil {
+		if channel, exists := m.findBuffer(net.name, name); exists {
+			return channel, true
+		}
+	}
+	for _, key := range m.channelOrder {
+		if channel := m.channels[key]; strings.EqualFold(channel.name, name) {
+			return channel, true
+		}
+	}
+	return nil, false
+}
+
+// bufferLabel names a buffer for display, qualified by its network when
+// more than one network is configured
+func (m *model) bufferLabel(key string) string {
+	channel, exists := m.channels[key]
+	if !exists {
+		return ""
+	}
+	if len(m.networkOrder) > 1 {
+		return channel.network + "/" + channel.name
 	}
+	return channel.name
 }
 
-func (m *model) setChannelActive(channelName string, active bool) {
-	if channel, exists := m.channels[channelName]; exists {
+// openQuery returns the query buffer for nick, creating it if needed
+func (m *model) openQuery(network, nick string) *channelData {
+	if existing, ok := m.findBuffer(network, nick); ok {
+		return existing
+	}
+	m.logger.Debug("Opening query with %s on %s", nick, network)
+	channel := &channelData{
+		network:  network,
+		name:     nick,
+		messages: newMessageRing(m.config.UI.ScrollbackLines),
+		joined:   true,
+		isQuery:  true,
+	}
+	m.channels[channel.key()] = channel
+	m.channelOrder = append(m.channelOrder, channel.key())
+	m.replayChatLog(channel)
+	return channel
+}
+
+// renameQuery follows a nick change so the conversation stays in one buffer
+func (m *model) renameQuery(network, oldNick, newNick string) (*channelData, bool) {
+	channel, ok := m.findBuffer(network, oldNick)
+	if !ok || !channel.isQuery {
+		return nil, false
+	}
+	if _, taken := m.findBuffer(network, newNick); taken && !strings.EqualFold(oldNick, newNick) {
+		return nil, false
+	}
+
+	oldKey := channel.key()
+	m.chatLog.closeBuffer(oldKey)
+	delete(m.channels, oldKey)
+	channel.name = newNick
+	newKey := channel.key()
+	m.channels[newKey] = channel
+
+	for i, key := range m.channelOrder {
+		if key == oldKey {
+			m.channelOrder[i] = newKey
+		}
+	}
+	for i, key := range m.activeChannels {
+		if key == oldKey {
+			m.activeChannels[i] = newKey
+		}
+	}
+	if m.currentBuffer == oldKey {
+		m.currentBuffer = newKey
+	}
+	return channel, true
+}
+
+// closeBuffer removes a buffer, parting it first if it is a joined channel
+func (m *model) closeBuffer(key string) {
+	channel, exists := m.channels[key]
+	if !exists {
+		return
+	}
+	if net := m.network(channel.network); !channel.isQuery && channel.joined && net != nil && net.connected {
+		net.session.Part(channel.name)
+	}
+
+	label := m.bufferLabel(key)
+	m.setChannelActive(key, false)
+	m.chatLog.closeBuffer(key)
+	delete(m.channels, key)
+	for i, bufferKey := range m.channelOrder {
+		if bufferKey == key {
+			m.channelOrder = append(m.channelOrder[:i], m.channelOrder[i+1:]...)
+			break
+		}
+	}
+
+	if m.currentBuffer == key {
+		m.currentBuffer = ""
+		m.messages.clear()
+		m.viewport.reset()
+		m.newBelow = 0
+		if buffers := m.getOpenBuffers(); len(buffers) > 0 {
+			m.switchToChannel(buffers[0])
+		}
+	}
+	m.addMessage(formatSystemMessage(fmt.Sprintf("Closed %s", label)))
+}
+
+func (m *model) setChannelActive(key string, active bool) {
+	if channel, exists := m.channels[key]; exists {
 		channel.active = active
-		if active && !m.isChannelInActiveList(channelName) {
-			m.activeChannels = append(m.activeChannels, channelName)
+		if active && !m.isChannelInActiveList(key) {
+			m.activeChannels = append(m.activeChannels, key)
 		} else if !active {
 			for i, ch := range m.activeChannels {
-				if ch == channelName {
+				if ch == key {
 					m.activeChannels = append(m.activeChannels[:i], m.activeChannels[i+1:]...)
 					break
 				}
@@ -37,9 +204,9 @@ func (m *model) setChannelActive(channelName string, active bool) {
 	}
 }
 
-func (m *model) setChannelJoined(channelName string, joined bool) {
+func (m *model) setChannelJoined(network, channelName string, joined bool) {
 	m.logger.Debug("Setting channel %s joined status to: %v", channelName, joined)
-	if channel, exists := m.channels[channelName]; exists {
+	if channel, exists := m.findBuffer(network, channelName); exists {
 		channel.joined = joined
 		m.logger.Debug("Channel %s joined status updated successfully", channelName)
 	} else {
@@ -47,84 +214,124 @@ func (m *model) setChannelJoined(channelName string, joined bool) {
 	}
 }
 
-func (m *model) isChannelInActiveList(channelName string) bool {
+func (m *model) isChannelInActiveList(key string) bool {
 	for _, ch := range m.activeChannels {
-		if ch == channelName {
+		if ch == key {
 			return true
 		}
 	}
 	return false
 }
 
-func (m *model) addMessageToChannel(channelName, message string) {
-	if channel, exists := m.channels[channelName]; exists {
-		channel.messages = append(channel.messages, message)
+func (m *model) addMessageToChannel(key string, message Message) {
+	if channel, exists := m.channels[key]; exists {
+		if message.Target == "" {
+			message.Target = channel.name
+		}
+		channel.messages.push(message)
+		m.logBufferMessage(channel, message)
+		if key != m.currentBuffer {
+			channel.markActivity(message)
+			if channel.scroll.scrolled {
+				channel.scroll.newBelow++
+				channel.scroll.arrived++
+			}
+		}
+	}
+}
+
+// addBufferMessage stores a message in a buffer and shows it if that buffer is in view
+func (m *model) addBufferMessage(key string, message Message) {
+	m.addMessageToChannel(key, message)
+	if key == m.currentBuffer {
+		m.addMessage(message)
 	}
 }
 
-func (m *model) switchToChannel(channelName string) {
-	m.logger.Debug("Attempting to switch to channel: %s", channelName)
-	if channel, exists := m.channels[channelName]; exists {
-		m.logger.Debug("Channel %s exists, joined: %v", channelName, channel.joined)
+func (m *model) switchToChannel(key string) {
+	m.logger.Debug("Attempting to switch to buffer: %s", key)
+	if channel, exists := m.channels[key]; exists {
+		m.logger.Debug("Buffer %s exists, joined: %v", key, channel.joined)
 		if channel.joined {
-			// Deactivate current channel
-			if m.currentChannel != "" {
-				m.setChannelActive(m.currentChannel, false)
+			// Deactivate current channel, remembering where it was read to
+			if m.currentBuffer != "" {
+				m.saveScroll(m.currentBuffer)
+				m.setChannelActive(m.currentBuffer, false)
 			}
 
 			// Switch to new channel
-			previousChannel := m.currentChannel
-			m.currentChannel = channelName
-			m.setChannelActive(channelName, true)
+			previousBuffer := m.currentBuffer
+			m.currentBuffer = key
+			m.setChannelActive(key, true)
+			channel.markRead()
 
 			// Update viewport with channel messages
-			m.messages = make([]string, len(channel.messages))
-			copy(m.messages, channel.messages)
-			m.viewport.SetContent(strings.Join(m.messages, "\n"))
-			m.viewport.GotoBottom()
+			m.messages.replace(channel.messages)
+			m.viewport.reset()
+			m.restoreScroll(channel)
+			m.updateDimensions()
 
-			m.logger.Debug("Successfully switched to channel: %s", channelName)
+			m.logger.Debug("Successfully switched to buffer: %s", key)
 
 			// Add a subtle indication of the channel switch if it's not during initial join
-			if previousChannel != "" && previousChannel != channelName {
+			// Skipped when returning to a read position so it does not count as new
+			if previousBuffer != "" && previousBuffer != key && !channel.scroll.scrolled {
 				// Add channel switch indication to the new channel's message history
-				switchMsg := formatChannelSwitchMessage(fmt.Sprintf("• Now viewing %s", channelName))
+				switchMsg := formatChannelSwitchMessage(fmt.Sprintf("• Now viewing %s", m.bufferLabel(key)))
 				m.addMessage(switchMsg)
 			}
 		} else {
-			m.logger.Debug("Channel %s not joined yet", channelName)
+			m.logger.Debug("Buffer %s not joined yet", key)
 		}
 	} else {
-		m.logger.Debug("Channel %s does not exist in channels map", channelName)
+		m.logger.Debug("Buffer %s does not exist in channels map", key)
 	}
 }
 
-func (m *model) getChannelsList() []string {
-	var result []string
-	for _, channelName := range m.channelOrder {
-		if channel, exists := m.channels[channelName]; exists && channel.joined {
-			status := ""
-			if channel.active {
-				status = " [ACTIVE]"
-			}
-			result = append(result, channelName+status)
-		}
+// getJoinedChannels returns the keys of joined channels, grouped by network
+func (m *model) getJoinedChannels() []string {
+	var joined []string
+	for _, network := range m.networkOrder {
+		joined = append(joined, m.networkChannels(network)...)
 	}
-	return result
+	return joined
 }
 
-func (m *model) getJoinedChannels() []string {
+// networkChannels returns the keys of the joined channels on one network
+func (m *model) networkChannels(network string) []string {
 	var joined []string
-	for _, channelName := range m.channelOrder {
-		if channel, exists := m.channels[channelName]; exists && channel.joined {
-			joined = append(joined, channelName)
+	for _, key := range m.channelOrder {
+		if channel := m.channels[key]; channel.network == network && channel.joined && !channel.isQuery {
+			joined = append(joined, key)
 		}
 	}
 	return joined
 }
 
+// networkQueries returns the keys of the query buffers on one network
+func (m *model) networkQueries(network string) []string {
+	var queries []string
+	for _, key := range m.channelOrder {
+		if channel := m.channels[key]; channel.network == network && channel.isQuery {
+			queries = append(queries, key)
+		}
+	}
+	return queries
+}
+
+// getOpenBuffers returns each network's joined channels followed by its
+// queries, in sidebar order
+func (m *model) getOpenBuffers() []string {
+	var buffers []string
+	for _, network := range m.networkOrder {
+		buffers = append(buffers, m.networkChannels(network)...)
+		buffers = append(buffers, m.networkQueries(network)...)
+	}
+	return buffers
+}
+
 func (m *model) nextChannel() {
-	joinedChannels := m.getJoinedChannels()
+	joinedChannels := m.getOpenBuffers()
 	if len(joinedChannels) <= 1 {
 		if len(joinedChannels) == 1 {
 			// Show message that there's only one channel
@@ -135,7 +342,7 @@ func (m *model) nextChannel() {
 
 	currentIndex := -1
 	for i, ch := range joinedChannels {
-		if ch == m.currentChannel {
+		if ch == m.currentBuffer {
 			currentIndex = i
 			break
 		}
@@ -151,12 +358,12 @@ func (m *model) nextChannel() {
 	nextChannel := joinedChannels[nextIndex]
 
 	// Show channel switch notification
-	m.addMessage(formatChannelSwitchMessage(fmt.Sprintf("→ Switched to %s (%d/%d)", nextChannel, nextIndex+1, len(joinedChannels))))
+	m.addMessage(formatChannelSwitchMessage(fmt.Sprintf("→ Switched to %s (%d/%d)", m.bufferLabel(nextChannel), nextIndex+1, len(joinedChannels))))
 	m.switchToChannel(nextChannel)
 }
 
 func (m *model) prevChannel() {
-	joinedChannels := m.getJoinedChannels()
+	joinedChannels := m.getOpenBuffers()
 	if len(joinedChannels) <= 1 {
 		if len(joinedChannels) == 1 {
 			// Show message that there's only one channel
@@ -167,7 +374,7 @@ func (m *model) prevChannel() {
 
 	currentIndex := -1
 	for i, ch := range joinedChannels {
-		if ch == m.currentChannel {
+		if ch == m.currentBuffer {
 			currentIndex = i
 			break
 		}
@@ -183,6 +390,6 @@ func (m *model) prevChannel() {
 	prevChannel := joinedChannels[prevIndex]
 
 	// Show channel switch notification
-	m.addMessage(formatChannelSwitchMessage(fmt.Sprintf("← Switched to %s (%d/%d)", prevChannel, prevIndex+1, len(joinedChannels))))
+	m.addMessage(formatChannelSwitchMessage(fmt.Sprintf("← Switched to %s (%d/%d)", m.bufferLabel(prevChannel), prevIndex+1, len(joinedChannels))))
 	m.switchToChannel(prevChannel)
 }
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultReplayLines = 100
+	chatLogExt         = ".jsonl"
+	chatLogDateFormat  = "2006-01-02"
+	chatLogLookback    = 7 // Daily files searched for replay lines
+)
+
+// chatLogger writes buffer history to one file per network, channel and
+// day, as JSON lines:
+//
+//	<log path>/chat/<network>/<channel>/<YYYY-MM-DD>.jsonl
+type chatLogger struct {
+	dir   string
+	files map[string]*chatLogFile // By buffer key
+}
+
+type chatLogFile struct {
+	path string
+	file *os.File
+}
+
+func newChatLogger(config *Config) *chatLogger {
+	return &chatLogger{
+		dir:   config.GetChatLogDir(),
+		files: make(map[string]*chatLogFile),
+	}
+}
+
+// bufferDir is the directory holding a buffer's daily files
+func (l *chatLogger) bufferDir(network, name string) string {
+	return filepath.Join(l.dir, logFileName(network), logFileName(name))
+}
+
+// logFileName makes a network or channel name safe to use as a file name
+func logFileName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', 0:
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || strings.Trim(name, ".") == "" {
+		return "_"
+	}
+	return name
+}
+
+// write appends a message to the buffer's file for the day it was sent,
+// moving on to a new file when the date changes
+func (l *chatLogger) write(channel *channelData, msg Message) error {
+	dir := l.bufferDir(channel.network, channel.name)
+	path := filepath.Join(dir, msg.Time.Format(chatLogDateFormat)+chatLogExt)
+
+	key := channel.key()
+	current := l.files[key]
+	if current == nil || current.path != path {
+		if current != nil {
+			current.file.Close()
+			delete(l.files, key)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create chat log directory: %w", err)
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open chat log: %w", err)
+		}
+		current = &chatLogFile{path: path, file: file}
+		l.files[key] = current
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = current.file.Write(append(data, '\n'))
+	return err
+}
+
+// closeBuffer closes the file of a buffer that is closed or renamed
+func (l *chatLogger) closeBuffer(key string) {
+	if l == nil {
+		return
+	}
+	if current := l.files[key]; current != nil {
+		current.file.Close()
+		delete(l.files, key)
+	}
+}
+
+// Close closes every open log file
+func (l *chatLogger) Close() {
+	if l == nil {
+		return
+	}
+	for key := range l.files {
+		l.closeBuffer(key)
+	}
+}
+
+// tail reads back up to n of the newest messages of a buffer, oldest first,
+// looking through the most recent daily files
+func (l *chatLogger) tail(network, name string, n int) ([]Message, error) {
+	dir := l.bufferDir(network, name)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var days []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), chatLogExt) {
+			days = append(days, entry.Name())
+		}
+	}
+	// Dated names sort chronologically; newest first
+	sort.Sort(sort.Reverse(sort.StringSlice(days)))
+	if len(days) > chatLogLookback {
+		days = days[:chatLogLookback]
+	}
+
+	var messages []Message
+	for _, day := range days {
+		dayMessages, err := readChatLog(filepath.Join(dir, day))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(dayMessages, messages...)
+		if len(messages) >= n {
+			break
+		}
+	}
+	if len(messages) > n {
+		messages = messages[len(messages)-n:]
+	}
+	return messages, nil
+}
+
+// readChatLog parses one daily file, skipping lines it cannot read
+func readChatLog(path string) ([]Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var messages []Message
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err == nil {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, scanner.Err()
+}
+
+// logBufferMessage records a message in the buffer's chat log
+func (m *model) logBufferMessage(channel *channelData, msg Message) {
+	if m.chatLog == nil || !m.config.Logging.ChatLogs || msg.Kind == KindSwitch || msg.Kind == KindSeparator {
+		return
+	}
+	if err := m.chatLog.write(channel, msg); err != nil {
+		m.logger.LogError("Failed to write chat log for %s: %v", channel.name, err)
+	}
+}
+
+// replayChatLog loads the end of a new buffer's saved history, followed by
+// a separator so it is not mistaken for live traffic
+func (m *model) replayChatLog(channel *channelData) {
+	if m.chatLog == nil || !m.config.Logging.ChatLogs || m.config.Logging.ReplayLines <= 0 {
+		return
+	}
+	messages, err := m.chatLog.tail(channel.network, channel.name, m.config.Logging.ReplayLines)
+	if err != nil {
+		m.logger.LogError("Failed to read chat log for %s: %v", channel.name, err)
+		return
+	}
+	if len(messages) == 0 {
+		return
+	}
+	for _, msg := range messages {
+		channel.messages.push(msg)
+	}
+	last := messages[len(messages)-1].Time
+	separator := fmt.Sprintf("─── End of saved history (last message %s) ───", last.Local().Format("2006-01-02 15:04"))
+	channel.messages.push(Message{Time: now(), Kind: KindSeparator, Target: channel.name, Text: separator})
+}
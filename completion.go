@@ -0,0 +1,251 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"goirc/session"
+)
+
+// completionCommands are offered when completing a command name
+var completionCommands = []string{
+	"/close", "/config", "/ctcp", "/disconnect", "/filter", "/formatting", "/help",
+	"/ignore", "/ignores", "/join", "/leave", "/logging", "/me", "/msg", "/names",
+	"/networks", "/nick", "/nicklist", "/part", "/q", "/query", "/quit", "/reconnect",
+	"/switch", "/sw", "/theme", "/topic", "/unignore",
+}
+
+// completionKeywords are the fixed arguments of commands that take them
+var completionKeywords = map[string][]string{
+	"/config":     {"show", "save", "reload"},
+	"/filter":     {"on", "off"},
+	"/formatting": {"show", "strip"},
+	"/logging":    {"on", "off", "chat", "debug", "status"},
+	"/log":        {"on", "off", "chat", "debug", "status"},
+	"/reconnect":  {"now"},
+}
+
+// completionState remembers a Tab cycle so repeated presses step through
+// the candidates instead of starting over
+type completionState struct {
+	before     string // Input before the word being completed
+	after      string // Input after the cursor
+	candidates []string
+	suffix     string // Appended after the candidate, e.g. ": " for a nick at line start
+	index      int
+	value      string // Input as we left it; any other edit ends the cycle
+}
+
+// complete handles Tab (forward) and Shift+Tab (backward) in the input box
+func (m *model) complete(forward bool) {
+	value := m.textarea.Value()
+	info := m.textarea.LineInfo()
+	cursor := info.StartColumn + info.ColumnOffset
+
+	state := m.completion
+	if state == nil || state.value != value {
+		state = m.newCompletion(value, cursor)
+		if state == nil {
+			return
+		}
+		if !forward {
+			state.index = len(state.candidates) - 1
+		}
+	} else if forward {
+		state.index = (state.index + 1) % len(state.candidates)
+	} else {
+		state.index = (state.index - 1 + len(state.candidates)) % len(state.candidates)
+	}
+
+	head := state.before + state.candidates[state.index] + state.suffix
+	state.value = head + state.after
+	m.completion = state
+
+	m.textarea.SetValue(state.value)
+	m.textarea.SetCursor(len([]rune(head)))
+}
+
+// newCompletion works out what the word under the cursor is and what it
+// could become. It returns nil when there is nothing to offer.
+func (m *model) newCompletion(value string, cursor int) *completionState {
+	runes := []rune(value)
+	if cursor > len(runes) {
+		cursor = len(runes)
+	}
+	head := string(runes[:cursor])
+	start := strings.LastIndex(head, " ") + 1
+	before, word := head[:start], head[start:]
+
+	var candidates []string
+	suffix := " "
+	switch {
+	case before == "" && strings.HasPrefix(word, "/"):
+		candidates = completionCommands
+	case strings.HasPrefix(before, "/"):
+		candidates = m.commandArgCandidates(strings.Fields(before), word)
+	case isChannelName(word):
+		candidates = m.channelCandidates()
+	case word != "":
+		candidates = m.nickCandidates()
+		if before == "" {
+			suffix = ": "
+		}
+	}
+
+	candidates = matchPrefix(candidates, word)
+	if len(candidates) == 0 {
+		return nil
+	}
+	return &completionState{
+		before:     before,
+		after:      string(runes[cursor:]),
+		candidates: candidates,
+		suffix:     suffix,
+	}
+}
+
+// commandArgCandidates offers arguments for a command; fields holds the
+// command and any arguments already typed before the current word
+func (m *model) commandArgCandidates(fields []string, word string) []string {
+	command := strings.ToLower(fields[0])
+	argIndex := len(fields) - 1
+
+	if keywords, ok := completionKeywords[command]; ok && argIndex == 0 {
+		return keywords
+	}
+	switch command {
+	case "/join":
+		return m.channelCandidates()
+	case "/part", "/leave", "/names":
+		if argIndex == 0 {
+			return m.channelCandidates()
+		}
+	case "/switch", "/sw", "/close":
+		if argIndex == 0 {
+			return m.bufferCandidates()
+		}
+	case "/ctcp":
+		if argIndex == 1 {
+			return strings.Fields(session.CTCPClientInfo)
+		}
+		if argIndex == 0 {
+			return m.nickCandidates()
+		}
+	case "/msg", "/query", "/q":
+		if argIndex == 0 {
+			return append(m.nickCandidates(), m.channelCandidates()...)
+		}
+	case "/theme":
+		if argIndex == 0 {
+			return append(themeNames(), "list")
+		}
+	case "/ignore":
+		// Levels follow the mask, which may follow -time <duration>
+		args := fields[1:]
+		if len(args) > 0 && strings.EqualFold(args[0], "-time") {
+			if len(args) < 2 {
+				return nil
+			}
+			args = args[2:]
+		}
+		if len(args) >= 1 {
+			return ignoreLevels
+		}
+	}
+	if word == "" {
+		return nil
+	}
+	if isChannelName(word) {
+		return m.channelCandidates()
+	}
+	return m.nickCandidates()
+}
+
+// nickCandidates lists the members of the current channel, or the other
+// side of a query, leaving out our own nick
+func (m *model) nickCandidates() []string {
+	channel, exists := m.channels[m.currentBuffer]
+	if !exists {
+		return nil
+	}
+	if channel.isQuery {
+		return []string{channel.name}
+	}
+
+	own := ""
+	if net := m.currentNetwork(); net != nil {
+		own = net.nick
+	}
+	members := m.members(channel)
+	nicks := make([]string, 0, len(members))
+	for nick := range members {
+		if !strings.EqualFold(nick, own) {
+			nicks = append(nicks, nick)
+		}
+	}
+	sortFold(nicks)
+	return nicks
+}
+
+// channelCandidates lists the channels open on the current network along
+// with the configured ones
+func (m *model) channelCandidates() []string {
+	net := m.currentNetwork()
+	if net == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var channels []string
+	add := func(name string) {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			channels = append(channels, name)
+		}
+	}
+	for _, key := range m.channelOrder {
+		if channel := m.channels[key]; channel.network == net.name && !channel.isQuery {
+			add(channel.name)
+		}
+	}
+	for _, name := range net.config.Channels {
+		add(name)
+	}
+	sortFold(channels)
+	return channels
+}
+
+// bufferCandidates lists every open buffer by the name /switch accepts:
+// bare on the current network, network-qualified elsewhere
+func (m *model) bufferCandidates() []string {
+	current := ""
+	if net := m.currentNetwork(); net != nil {
+		current = net.name
+	}
+	var buffers []string
+	for _, key := range m.getOpenBuffers() {
+		if channel := m.channels[key]; channel.network == current {
+			buffers = append(buffers, channel.name)
+		} else {
+			buffers = append(buffers, m.bufferLabel(key))
+		}
+	}
+	return buffers
+}
+
+// matchPrefix keeps the candidates starting with prefix, ignoring case
+func matchPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	lower := strings.ToLower(prefix)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), lower) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+func sortFold(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+}
@@ -3,40 +3,110 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 // Config represents the application configuration
 type Config struct {
-	IRC      IRCConfig `json:"irc"`
-	UI       UIConfig  `json:"ui"`
-	Logging  LogConfig `json:"logging"`
-	FilePath string    `json:"-"` // Don't serialize the file path
+	IRC       IRCConfig       `json:"irc"`                // Primary network, edited by the setup wizard
+	Networks  []IRCConfig     `json:"networks,omitempty"` // Additional networks connected alongside it
+	UI        UIConfig        `json:"ui"`
+	Logging   LogConfig       `json:"logging"`
+	Highlight HighlightConfig `json:"highlight"`
+	Ignores   []IgnoreEntry   `json:"ignores,omitempty"`
+	FilePath  string          `json:"-"` // Don't serialize the file path
 }
 
 // IRCConfig contains IRC-related configuration
 type IRCConfig struct {
-	Server   string   `json:"server"`
-	Port     int      `json:"port,omitempty"`
-	Nick     string   `json:"nick"`
-	Username string   `json:"username,omitempty"`
-	RealName string   `json:"realname,omitempty"`
-	Channels []string `json:"channels"`
-	UseSSL   bool     `json:"use_ssl"`
-	Password string   `json:"password,omitempty"`
-	QuitMsg  string   `json:"quit_message,omitempty"`
+	Name     string     `json:"name,omitempty"` // Defaults to a name derived from Server
+	Server   string     `json:"server"`
+	Port     int        `json:"port,omitempty"`
+	Nick     string     `json:"nick"`
+	Username string     `json:"username,omitempty"`
+	RealName string     `json:"realname,omitempty"`
+	Channels []string   `json:"channels"`
+	UseSSL   bool       `json:"use_ssl"`
+	Password string     `json:"password,omitempty"`
+	QuitMsg  string     `json:"quit_message,omitempty"`
+	SASL     SASLConfig `json:"sasl"`
+
+	// Per-channel behaviour, keyed by channel name
+	ChannelSettings map[string]ChannelSettings `json:"channel_settings,omitempty"`
+}
+
+// ChannelSettings holds options for a single channel
+type ChannelSettings struct {
+	AutoRejoin bool `json:"auto_rejoin,omitempty"` // Rejoin after being kicked
+	// How highlights alert: none, bell, desktop or all; empty uses the
+	// highlight settings
+	Notify string `json:"notify,omitempty"`
+	// Hide joins, parts and quits from nicks that have not spoken recently
+	SmartFilter bool `json:"smart_filter,omitempty"`
+}
+
+// HighlightConfig controls which messages mention us and how we are told.
+// Our nick always highlights. Words match whole words ignoring case;
+// Patterns and Exclude are regular expressions matched against the text.
+type HighlightConfig struct {
+	Words        []string `json:"words,omitempty"`
+	Patterns     []string `json:"patterns,omitempty"`
+	Exclude      []string `json:"exclude,omitempty"`       // Messages matching these never highlight
+	ExcludeNicks []string `json:"exclude_nicks,omitempty"` // Senders that never highlight, e.g. bots
+	Bell         bool     `json:"bell"`
+	Desktop      string   `json:"desktop,omitempty"` // osc9, osc777, or empty for none
+}
+
+// IgnoreEntry hides events from users matching a nick!user@host mask, where
+// * and ? are wildcards. Levels lists the kinds of events hidden (msgs,
+// notices, ctcps, joins, parts, quits, nicks); empty means all of them.
+type IgnoreEntry struct {
+	Mask    string    `json:"mask"`
+	Levels  []string  `json:"levels,omitempty"`
+	Expires time.Time `json:"expires,omitzero"` // Zero never expires
+}
+
+// SASLConfig contains SASL authentication settings. Mechanism is one of
+// PLAIN, EXTERNAL or SCRAM-SHA-256; leave it empty to disable SASL.
+type SASLConfig struct {
+	Mechanism string `json:"mechanism,omitempty"`
+	Account   string `json:"account,omitempty"`
+	Password  string `json:"password,omitempty"`
+	CertFile  string `json:"cert_file,omitempty"` // Client certificate for EXTERNAL
+	KeyFile   string `json:"key_file,omitempty"`  // Optional if the key is in CertFile
 }
 
 // UIConfig contains UI-related configuration
 type UIConfig struct {
 	ShowSidebar  bool `json:"show_sidebar"`
 	SidebarWidth int  `json:"sidebar_width"`
-	Theme        struct {
-		Primary   string `json:"primary"`
-		Secondary string `json:"secondary"`
-		Accent    string `json:"accent"`
-	} `json:"theme"`
+	ShowNicklist bool `json:"show_nicklist"`
+	// Messages kept per buffer; older ones are dropped
+	ScrollbackLines int `json:"scrollback_lines"`
+	// Show mIRC colors and bold/italic/underline as plain text
+	StripFormatting bool `json:"strip_formatting"`
+	// Built-in theme or file in the themes directory; "auto" picks dark
+	// or light to match the terminal
+	Theme ThemeSetting `json:"theme"`
+}
+
+// ThemeSetting is the name of the theme to use
+type ThemeSetting string
+
+// UnmarshalJSON also accepts the old {"primary": ...} color object, which
+// was never applied, as "auto"
+func (t *ThemeSetting) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		*t = themeAuto
+		return nil
+	}
+	*t = ThemeSetting(name)
+	return nil
 }
 
 // LogConfig contains logging configuration
@@ -45,6 +115,10 @@ type LogConfig struct {
 	MaxSizeKB int    `json:"max_size_kb"`
 	LogPath   string `json:"log_path"`
 	DebugMode bool   `json:"debug_mode"`
+
+	// Per-channel chat logs, rotated daily, independent of the debug log
+	ChatLogs    bool `json:"chat_logs"`
+	ReplayLines int  `json:"replay_lines"` // Saved lines reloaded when a buffer opens
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -64,23 +138,23 @@ func DefaultConfig() *Config {
 			QuitMsg:  "Goodbye from GoIRC!",
 		},
 		UI: UIConfig{
-			ShowSidebar:  true,
-			SidebarWidth: 30,
-			Theme: struct {
-				Primary   string `json:"primary"`
-				Secondary string `json:"secondary"`
-				Accent    string `json:"accent"`
-			}{
-				Primary:   "#7C3AED",
-				Secondary: "#A855F7",
-				Accent:    "#EC4899",
-			},
+			ShowSidebar:     true,
+			SidebarWidth:    30,
+			ShowNicklist:    true,
+			ScrollbackLines: defaultScrollbackLines,
+			Theme:           themeAuto,
 		},
 		Logging: LogConfig{
 			Enabled:   false, // Disabled by default to prevent log spam
 			MaxSizeKB: 512,
 			LogPath:   filepath.Join(configDir, "logs"),
 			DebugMode: false,
+
+			ChatLogs:    true,
+			ReplayLines: defaultReplayLines,
+		},
+		Highlight: HighlightConfig{
+			Bell: true,
 		},
 		FilePath: filepath.Join(configDir, "config.json"),
 	}
@@ -128,8 +202,12 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	configPath := filepath.Join(configDir, "config.json")
+	return LoadConfigFile(filepath.Join(configDir, "config.json"))
+}
 
+// LoadConfigFile loads configuration from a config file, creating it with
+// the defaults if it does not exist
+func LoadConfigFile(configPath string) (*Config, error) {
 	// Check if config file exists
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		// Create default config
@@ -165,6 +243,12 @@ func LoadConfig() (*Config, error) {
 	if config.Logging.LogPath == "" {
 		config.Logging.LogPath = defaultConfig.Logging.LogPath
 	}
+	if config.UI.ScrollbackLines <= 0 {
+		config.UI.ScrollbackLines = defaultConfig.UI.ScrollbackLines
+	}
+	if config.UI.Theme == "" {
+		config.UI.Theme = defaultConfig.UI.Theme
+	}
 
 	return &config, nil
 }
@@ -197,6 +281,11 @@ func (c *Config) Save() error {
 	return nil
 }
 
+// GetChatLogDir returns the directory holding the per-channel chat logs
+func (c *Config) GetChatLogDir() string {
+	return filepath.Join(filepath.Dir(c.GetLogFilePath()), "chat")
+}
+
 // GetLogFilePath returns the current log file path
 func (c *Config) GetLogFilePath() string {
 	if c.Logging.LogPath == "" {
@@ -215,16 +304,156 @@ func (c *Config) GetDebugLogFilePath() string {
 	return filepath.Join(c.Logging.LogPath, "debug.log")
 }
 
-// Validate checks if the configuration is valid
-func (c *Config) Validate() error {
-	if c.IRC.Server == "" {
-		return fmt.Errorf("IRC server cannot be empty")
+// AllNetworks returns the primary network followed by the additional ones.
+// Identity fields left empty on an additional network are taken from the
+// primary one.
+func (c *Config) AllNetworks() []IRCConfig {
+	networks := []IRCConfig{c.IRC}
+	for _, n := range c.Networks {
+		if n.Nick == "" {
+			n.Nick = c.IRC.Nick
+		}
+		if n.Username == "" {
+			n.Username = c.IRC.Username
+		}
+		if n.RealName == "" {
+			n.RealName = c.IRC.RealName
+		}
+		if n.QuitMsg == "" {
+			n.QuitMsg = c.IRC.QuitMsg
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}
+
+// NetworkConfig returns the stored settings of a network by name, so they
+// can be changed and saved
+func (c *Config) NetworkConfig(name string) *IRCConfig {
+	if c.IRC.NetworkName() == name {
+		return &c.IRC
+	}
+	for i := range c.Networks {
+		if c.Networks[i].NetworkName() == name {
+			return &c.Networks[i]
+		}
+	}
+	return nil
+}
+
+// Settings returns the options for a channel, matching its name case-insensitively
+func (n IRCConfig) Settings(channel string) ChannelSettings {
+	if settings, ok := n.ChannelSettings[channel]; ok {
+		return settings
+	}
+	for name, settings := range n.ChannelSettings {
+		if strings.EqualFold(name, channel) {
+			return settings
+		}
+	}
+	return ChannelSettings{}
+}
+
+// SetSettings stores the options for a channel, replacing any stored under
+// another case of its name
+func (n *IRCConfig) SetSettings(channel string, settings ChannelSettings) {
+	for name := range n.ChannelSettings {
+		if strings.EqualFold(name, channel) {
+			delete(n.ChannelSettings, name)
+		}
+	}
+	if settings == (ChannelSettings{}) {
+		return
+	}
+	if n.ChannelSettings == nil {
+		n.ChannelSettings = make(map[string]ChannelSettings)
 	}
-	if c.IRC.Nick == "" {
-		return fmt.Errorf("IRC nick cannot be empty")
+	n.ChannelSettings[channel] = settings
+}
+
+// NetworkName returns the configured name, or one derived from the server
+// host: irc.libera.chat becomes "libera"
+func (n IRCConfig) NetworkName() string {
+	if n.Name != "" {
+		return n.Name
+	}
+	host := strings.Split(n.Server, ":")[0]
+	if net.ParseIP(host) != nil {
+		return host
 	}
+	labels := strings.Split(host, ".")
+	if len(labels) > 2 && (labels[0] == "irc" || labels[0] == "chat") {
+		labels = labels[1:]
+	}
+	return labels[0]
+}
+
+// Validate checks if the configuration is valid
+func (c *Config) Validate() error {
 	if c.Logging.MaxSizeKB <= 0 {
 		return fmt.Errorf("log max size must be positive")
 	}
+	seen := make(map[string]bool)
+	for _, n := range c.AllNetworks() {
+		if n.Server == "" {
+			return fmt.Errorf("IRC server cannot be empty")
+		}
+		if n.Nick == "" {
+			return fmt.Errorf("IRC nick cannot be empty for %s", n.Server)
+		}
+		name := strings.ToLower(n.NetworkName())
+		if seen[name] {
+			return fmt.Errorf("duplicate network name %q; set a unique name for each network", n.NetworkName())
+		}
+		seen[name] = true
+		if err := n.SASL.Validate(n.UseSSL); err != nil {
+			return fmt.Errorf("%s: %w", n.NetworkName(), err)
+		}
+		for channel, settings := range n.ChannelSettings {
+			switch settings.Notify {
+			case notifyDefault, notifyOff, notifyBell, notifyDesktop, notifyAll:
+			default:
+				return fmt.Errorf("%s %s: notify must be none, bell, desktop or all", n.NetworkName(), channel)
+			}
+		}
+	}
+	switch c.Highlight.Desktop {
+	case "", desktopOSC9, desktopOSC777:
+	default:
+		return fmt.Errorf("highlight desktop must be %s or %s", desktopOSC9, desktopOSC777)
+	}
+	if _, err := newHighlighter(c.Highlight); err != nil {
+		return err
+	}
+	for _, entry := range c.Ignores {
+		if entry.Mask == "" {
+			return fmt.Errorf("ignore entries need a mask")
+		}
+		if _, err := parseIgnoreLevels(entry.Levels); err != nil {
+			return fmt.Errorf("ignore %s: %w", entry.Mask, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the SASL settings are complete for the chosen mechanism
+func (s SASLConfig) Validate(useSSL bool) error {
+	switch strings.ToUpper(s.Mechanism) {
+	case "":
+		return nil
+	case saslPlain, saslScramSHA256:
+		if s.Account == "" || s.Password == "" {
+			return fmt.Errorf("SASL %s requires an account and password", s.Mechanism)
+		}
+	case saslExternal:
+		if !useSSL {
+			return fmt.Errorf("SASL EXTERNAL requires an SSL connection")
+		}
+		if s.CertFile == "" {
+			return fmt.Errorf("SASL EXTERNAL requires a client certificate")
+		}
+	default:
+		return fmt.Errorf("unsupported SASL mechanism: %s", s.Mechanism)
+	}
 	return nil
 }
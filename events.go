@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// coalesceWindow is how long IRC events are gathered after the first
+	// one so a burst reaches the UI as a single update
+	coalesceWindow = 10 * time.Millisecond
+	// maxBatch caps a batch so a flood still renders now and then
+	maxBatch = 512
+	// eventBuffer is how many events the handlers may run ahead of Update
+	// before they block
+	eventBuffer = 256
+)
+
+// ircBatchMsg carries IRC events that arrived together, in order
+type ircBatchMsg []tea.Msg
+
+// eventStream carries IRC events from the session goroutines to
+// Update. The handlers only ever send on it; the model reads it through a
+// command that Update re-arms after every batch, so the model itself is
+// only touched by the Bubble Tea loop.
+type eventStream struct {
+	ch        chan tea.Msg
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{
+		ch:   make(chan tea.Msg, eventBuffer),
+		done: make(chan struct{}),
+	}
+}
+
+// send queues an event, blocking while Update is behind. Events sent after
+// the stream is closed are dropped.
+func (s *eventStream) send(msg tea.Msg) {
+	select {
+	case s.ch <- msg:
+	case <-s.done:
+	}
+}
+
+// wait returns a command that blocks for the next event and gathers what
+// else arrives within the coalesce window into one batch
+func (s *eventStream) wait() tea.Cmd {
+	return func() tea.Msg {
+		var batch ircBatchMsg
+		select {
+		case msg := <-s.ch:
+			batch = append(batch, msg)
+		case <-s.done:
+			return nil
+		}
+
+		timeout := time.NewTimer(coalesceWindow)
+		defer timeout.Stop()
+		for len(batch) < maxBatch {
+			select {
+			case msg := <-s.ch:
+				batch = append(batch, msg)
+			case <-timeout.C:
+				return batch
+			case <-s.done:
+				return batch
+			}
+		}
+		return batch
+	}
+}
+
+// close stops the stream, releasing any handler blocked in send
+func (s *eventStream) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// logIRCEvent writes an event to the IRC event log. It runs in Update so
+// the logger and the config it reads stay on one goroutine.
+func (m *model) logIRCEvent(event tea.Msg) {
+	switch msg := event.(type) {
+	case ircAuthenticatedMsg:
+		m.logger.LogIRCEvent("SASL: %s", msg.message)
+	case ircConnectedMsg:
+		if net := m.network(msg.network); net != nil {
+			m.logger.LogIRCEvent("Connected to IRC server %s", net.config.Server)
+		}
+		m.logger.Debug("Our actual nickname on %s is: %s", msg.network, msg.nick)
+	case ircDisconnectedMsg:
+		if net := m.network(msg.network); net != nil {
+			m.logger.LogIRCEvent("Disconnected from IRC server %s", net.config.Server)
+		}
+	case ircErrorMsg:
+		m.logger.LogError("%v", msg.err)
+	case ircNickChangeMsg:
+		m.logger.LogIRCEvent("%s changed nick to %s", msg.oldNick, msg.newNick)
+	case ircCTCPMsg:
+		m.logger.LogIRCEvent("CTCP %s from %s", msg.command, msg.user)
+		if msg.rateLimited {
+			m.logger.LogIRCEvent("CTCP %s from %s ignored (rate limited)", msg.command, msg.user)
+		}
+	case ircCTCPReplyMsg:
+		m.logger.LogIRCEvent("CTCP %s reply from %s: %s", msg.command, msg.user, msg.args)
+	case ircNoticeMsg:
+		m.logger.LogIRCEvent("Notice from %s: %s", msg.user, msg.message)
+	case ircJoinMsg:
+		m.logger.LogIRCEvent("%s joined %s", msg.user, msg.channel)
+	case ircPartMsg:
+		m.logger.LogIRCEvent("%s left %s (%s)", msg.user, msg.channel, msg.message)
+	case ircQuitMsg:
+		m.logger.LogIRCEvent("%s quit (%s)", msg.user, msg.message)
+	case ircKickMsg:
+		m.logger.LogIRCEvent("%s kicked %s from %s (%s)", msg.kicker, msg.target, msg.channel, msg.reason)
+	case ircModeMsg:
+		modes := append([]string{msg.target, msg.modes}, msg.args...)
+		m.logger.LogIRCEvent("%s sets mode %s", msg.setter, strings.Join(modes, " "))
+	case ircTopicMsg:
+		if msg.changed {
+			m.logger.LogIRCEvent("%s changed topic of %s to: %s", msg.setBy, msg.channel, msg.topic)
+		}
+	case ircChannelErrorMsg:
+		m.logger.LogIRCEvent("Cannot join %s: %s", msg.channel, msg.message)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const (
+	burstUsers    = 50
+	burstLines    = 20 // Lines each user says
+	burstLeavers  = 10 // Users that part, and as many again that quit
+	burstDoneText = "end of burst"
+)
+
+// TestEventBurst floods the client with joins, messages, parts and quits
+// while the model is driven as the Bubble Tea loop drives it. Run with
+// -race to check the session's goroutines share nothing with the model.
+func TestEventBurst(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	c := startClient(t, srv, false)
+	conn := srv.accept()
+	conn.register()
+	c.waitForConnect()
+
+	var burst strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&burst, format+"\r\n", args...)
+	}
+	line(":tester!u@h JOIN #burst")
+	line(":irc.test 353 tester = #burst :tester")
+	line(":irc.test 366 tester #burst :End of /NAMES list")
+	for u := 0; u < burstUsers; u++ {
+		line(":user%d!u@h JOIN #burst", u)
+	}
+	for i := 0; i < burstLines; i++ {
+		for u := 0; u < burstUsers; u++ {
+			line(":user%d!u@h PRIVMSG #burst :line %d", u, i)
+		}
+	}
+	for u := 0; u < burstLeavers; u++ {
+		line(":user%d!u@h PART #burst :bye", u)
+		line(":user%d!u@h QUIT :gone", burstLeavers+u)
+	}
+	line(":user%d!u@h PRIVMSG #burst :%s", burstUsers-1, burstDoneText)
+
+	// The client stops reading while its events wait for Update, so the
+	// burst is written alongside; a failed write shows up as a timeout
+	go io.WriteString(conn.conn, burst.String())
+
+	var channel *channelData
+	c.waitFor("the end of the burst", func() bool {
+		var ok bool
+		if channel, ok = c.m.findBuffer(c.network().name, "#burst"); !ok {
+			return false
+		}
+		msgs := channel.messages.all()
+		return len(msgs) > 0 && msgs[len(msgs)-1].Text == burstDoneText
+	})
+
+	if got, want := len(c.m.members(channel)), 1+burstUsers-2*burstLeavers; got != want {
+		t.Errorf("got %d members, want %d", got, want)
+	}
+	chat := 0
+	for _, msg := range channel.messages.all() {
+		if msg.Kind == KindChat {
+			chat++
+		}
+	}
+	if want := burstUsers*burstLines + 1; chat != want {
+		t.Errorf("got %d chat lines, want %d", chat, want)
+	}
+}
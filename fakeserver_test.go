@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTimeout bounds every wait on the fake server
+const fakeTimeout = 5 * time.Second
+
+// fakeServer is a scriptable IRC server on localhost. Tests accept the
+// client's connection and then play the server's side line by line.
+type fakeServer struct {
+	t     *testing.T
+	ln    net.Listener
+	conns chan net.Conn
+	roots *x509.CertPool // Trusts the server's certificate when it speaks TLS
+}
+
+// newFakeServer listens on a free localhost port, speaking TLS when
+// tlsConfig is set
+func newFakeServer(t *testing.T, tlsConfig *tls.Config) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	s := &fakeServer{t: t, ln: ln, conns: make(chan net.Conn, 4)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				close(s.conns)
+				return
+			}
+			s.conns <- conn
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// newTLSFakeServer is a fake server speaking TLS with a certificate of
+// its own, which clients started with runClient trust
+func newTLSFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	cert, roots := testCertificate(t)
+	s := newFakeServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	s.roots = roots
+	return s
+}
+
+func (s *fakeServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// accept waits for the client to connect
+func (s *fakeServer) accept() *fakeConn {
+	s.t.Helper()
+	select {
+	case conn, ok := <-s.conns:
+		if !ok {
+			s.t.Fatal("fake server closed")
+		}
+		c := &fakeConn{t: s.t, conn: conn, lines: make(chan string, 64)}
+		go c.read()
+		s.t.Cleanup(c.close)
+		return c
+	case <-time.After(fakeTimeout):
+		s.t.Fatal("timed out waiting for the client to connect")
+	}
+	return nil
+}
+
+// fakeConn is the server's end of one client connection
+type fakeConn struct {
+	t     *testing.T
+	conn  net.Conn
+	lines chan string // Lines from the client
+	nick  string
+}
+
+func (c *fakeConn) read() {
+	defer close(c.lines)
+	r := bufio.NewReader(c.conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		c.lines <- strings.TrimRight(line, "\r\n")
+	}
+}
+
+// send writes one line to the client
+func (c *fakeConn) send(format string, args ...any) {
+	c.t.Helper()
+	if _, err := fmt.Fprintf(c.conn, format+"\r\n", args...); err != nil {
+		c.t.Fatalf("sending to client: %v", err)
+	}
+}
+
+// expect skips client lines until one with the given command arrives and
+// returns its parameters
+func (c *fakeConn) expect(command string) []string {
+	c.t.Helper()
+	timeout := time.After(fakeTimeout)
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				c.t.Fatalf("client hung up while waiting for %s", command)
+			}
+			cmd, params := parseClientLine(line)
+			if cmd == command {
+				return params
+			}
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s from the client", command)
+		}
+	}
+}
+
+// register completes the client's registration and advertises the usual
+// membership prefixes
+func (c *fakeConn) register() {
+	c.t.Helper()
+	c.nick = c.expect("NICK")[0]
+	c.expect("USER")
+	c.send(":irc.test 001 %s :Welcome to the test network %s!user@localhost", c.nick, c.nick)
+	c.send(":irc.test 005 %s PREFIX=(ov)@+ CHANMODES=beI,k,l,imnpst :are supported by this server", c.nick)
+}
+
+// acceptJoin waits for the client to join a channel and answers with the
+// topic and member list, as a server does
+func (c *fakeConn) acceptJoin(channel, topic string, members ...string) {
+	c.t.Helper()
+	params := c.expect("JOIN")
+	if len(params) == 0 || !strings.EqualFold(params[0], channel) {
+		c.t.Fatalf("client joined %v, want %s", params, channel)
+	}
+	c.send(":%s!user@localhost JOIN %s", c.nick, channel)
+	if topic != "" {
+		c.send(":irc.test 332 %s %s :%s", c.nick, channel, topic)
+	}
+	c.send(":irc.test 353 %s = %s :%s", c.nick, channel, strings.Join(append([]string{c.nick}, members...), " "))
+	c.send(":irc.test 366 %s %s :End of /NAMES list", c.nick, channel)
+}
+
+// from sends a line as another user
+func (c *fakeConn) from(nick, format string, args ...any) {
+	c.t.Helper()
+	c.send(":%s!%s@users.test %s", nick, strings.ToLower(nick), fmt.Sprintf(format, args...))
+}
+
+// close drops the connection, as a server that goes away does
+func (c *fakeConn) close() {
+	c.conn.Close()
+}
+
+// parseClientLine splits a line from the client into its command and
+// parameters, with the trailing parameter unwrapped
+func parseClientLine(line string) (string, []string) {
+	line, trailing, hasTrailing := strings.Cut(line, " :")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	params := fields[1:]
+	if hasTrailing {
+		params = append(params, trailing)
+	}
+	return strings.ToUpper(fields[0]), params
+}
+
+// testCertificate makes a self-signed certificate for 127.0.0.1 and a pool
+// that trusts it
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "irc.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
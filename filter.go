@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// Joins, parts and quits are only shown for nicks that spoke this
+	// recently in channels with the smart filter on
+	smartFilterDelay = 10 * time.Minute
+	// Quits and rejoins of one netsplit are gathered this long before the
+	// summary is shown
+	netsplitDelay = 2 * time.Second
+	// Joins this soon after a split count as the split healing
+	netsplitRejoinWindow = 15 * time.Minute
+	netsplitShownNicks   = 8 // Nicks named in a summary before "+N more"
+)
+
+// netsplitReason matches the "server1 server2" quit message servers give
+// users lost in a netsplit. Users cannot send this themselves, as servers
+// prefix their quit messages.
+var netsplitReason = regexp.MustCompile(`^([a-zA-Z0-9*-]+\.[a-zA-Z0-9.*-]+) ([a-zA-Z0-9*-]+\.[a-zA-Z0-9.*-]+)$`)
+
+// netsplitMsg shows the summary of a netsplit's quits, or its rejoins
+type netsplitMsg struct {
+	network, servers string
+	rejoin           bool
+}
+
+// netsplits tracks the netsplits of one network
+type netsplits struct {
+	pending map[netsplitMsg]*splitBatch // Summaries waiting to be shown
+	lost    map[string]lostNick         // Lower-case nick -> the split it left in
+}
+
+// splitBatch gathers the nicks of one netsplit per buffer
+type splitBatch struct {
+	nicks map[string][]string // Buffer key -> nicks, in order
+	keys  []string            // Buffer keys in order of their first nick
+}
+
+type lostNick struct {
+	servers  string
+	at       time.Time
+	channels map[string]bool // Keys of the buffers it has yet to rejoin
+}
+
+// netsplitServers returns the "server1 <-> server2" of a netsplit quit
+// reason, or false if the reason is not a netsplit
+func netsplitServers(reason string) (string, bool) {
+	match := netsplitReason.FindStringSubmatch(reason)
+	if match == nil || strings.EqualFold(match[1], match[2]) {
+		return "", false
+	}
+	return match[1] + " <-> " + match[2], true
+}
+
+// add records a nick for the summary of a buffer. It returns a command to
+// show the summary when this is the first nick of the batch.
+func (s *netsplits) add(network, servers string, rejoin bool, key, nick string) tea.Cmd {
+	if s.pending == nil {
+		s.pending = make(map[netsplitMsg]*splitBatch)
+	}
+	id := netsplitMsg{network: network, servers: servers, rejoin: rejoin}
+	batch, exists := s.pending[id]
+	if !exists {
+		batch = &splitBatch{nicks: make(map[string][]string)}
+		s.pending[id] = batch
+	}
+	if _, seen := batch.nicks[key]; !seen {
+		batch.keys = append(batch.keys, key)
+	}
+	batch.nicks[key] = append(batch.nicks[key], nick)
+	if exists {
+		return nil
+	}
+	return tea.Tick(netsplitDelay, func(time.Time) tea.Msg { return id })
+}
+
+// lose remembers a nick that left the buffers keys in a split, so its
+// rejoins can be folded into the netjoin summary
+func (s *netsplits) lose(nick, servers string, keys []string) {
+	if s.lost == nil {
+		s.lost = make(map[string]lostNick)
+	}
+	now := time.Now()
+	for name, lost := range s.lost {
+		if now.Sub(lost.at) > netsplitRejoinWindow {
+			delete(s.lost, name)
+		}
+	}
+	channels := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		channels[key] = true
+	}
+	s.lost[strings.ToLower(nick)] = lostNick{servers: servers, at: now, channels: channels}
+}
+
+// returning reports the split a nick joining a buffer was lost in, if it
+// is recent. Each buffer's rejoin is matched once, and the nick is
+// forgotten when it has rejoined them all.
+func (s *netsplits) returning(nick, key string) (string, bool) {
+	name := strings.ToLower(nick)
+	lost, ok := s.lost[name]
+	if !ok || !lost.channels[key] || time.Since(lost.at) > netsplitRejoinWindow {
+		return "", false
+	}
+	delete(lost.channels, key)
+	if len(lost.channels) == 0 {
+		delete(s.lost, name)
+	}
+	return lost.servers, true
+}
+
+// handleNetsplitQuit removes a user lost in a split from every buffer and
+// queues them for the split summary instead of a quit line each
+func (m *model) handleNetsplitQuit(net *network, msg ircQuitMsg, servers string) tea.Cmd {
+	var cmds []tea.Cmd
+	keys := m.channelBuffers(net.name, msg.channels)
+	for _, key := range keys {
+		if !msg.hidden {
+			cmds = append(cmds, net.splits.add(net.name, servers, false, key, msg.user))
+		}
+	}
+	if query, ok := m.findBuffer(net.name, msg.user); ok && query.isQuery && !msg.hidden {
+		cmds = append(cmds, net.splits.add(net.name, servers, false, query.key(), msg.user))
+	}
+	net.splits.lose(msg.user, servers, keys)
+	return tea.Batch(cmds...)
+}
+
+// showNetsplit adds the summary lines of a netsplit batch
+func (m *model) showNetsplit(msg netsplitMsg) {
+	net := m.network(msg.network)
+	if net == nil {
+		return
+	}
+	batch, exists := net.splits.pending[msg]
+	if !exists {
+		return
+	}
+	delete(net.splits.pending, msg)
+	for _, key := range batch.keys {
+		if _, open := m.channels[key]; open {
+			m.addBufferMessage(key, formatNetsplitMessage(msg.servers, batch.nicks[key], msg.rejoin))
+		}
+	}
+}
+
+// noteSpoke records that a nick spoke in a channel, for the smart filter
+func (c *channelData) noteSpoke(nick string) {
+	if c.spoke == nil {
+		c.spoke = make(map[string]time.Time)
+	}
+	now := time.Now()
+	c.spoke[strings.ToLower(nick)] = now
+	// Forget nicks that have been quiet for a while now and then
+	if len(c.spoke) > 500 {
+		for name, at := range c.spoke {
+			if now.Sub(at) > smartFilterDelay {
+				delete(c.spoke, name)
+			}
+		}
+	}
+}
+
+// smartFiltered reports whether a join, part or quit by nick in a channel
+// is hidden because the channel has the smart filter on and the nick has
+// not spoken recently. Our own events always show.
+func (m *model) smartFiltered(channel *channelData, nick string) bool {
+	net := m.network(channel.network)
+	if net == nil || channel.isQuery || strings.EqualFold(nick, net.nick) {
+		return false
+	}
+	if !net.config.Settings(channel.name).SmartFilter {
+		return false
+	}
+	spoke, ok := channel.spoke[strings.ToLower(nick)]
+	return !ok || time.Since(spoke) > smartFilterDelay
+}
+
+// setSmartFilter turns the smart filter of a channel on or off and saves it
+func (m *model) setSmartFilter(net *network, channel string, on bool) {
+	settings := net.config.Settings(channel)
+	settings.SmartFilter = on
+	net.config.SetSettings(channel, settings)
+	if cfg := m.config.NetworkConfig(net.name); cfg != nil {
+		cfg.SetSettings(channel, settings)
+		m.saveConfig()
+	}
+}
+
+// handleFilterCommand runs /filter [on|off] for the current channel
+func (m *model) handleFilterCommand(args []string) {
+	channel, exists := m.channels[m.currentBuffer]
+	net := m.currentNetwork()
+	if !exists || channel.isQuery || net == nil {
+		m.addMessage(formatErrorMessage("/filter only works in a channel"))
+		return
+	}
+	if len(args) == 0 {
+		state := "off"
+		if net.config.Settings(channel.name).SmartFilter {
+			state = "on"
+		}
+		m.addMessage(formatSystemMessage(fmt.Sprintf("Smart filter for %s: %s (joins, parts and quits only show for nicks that spoke in the last %v)", channel.name, state, smartFilterDelay)))
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "on", "enable", "true":
+		m.setSmartFilter(net, channel.name, true)
+		m.addMessage(formatSystemMessage(fmt.Sprintf("Smart filter enabled for %s", channel.name)))
+	case "off", "disable", "false":
+		m.setSmartFilter(net, channel.name, false)
+		m.addMessage(formatSystemMessage(fmt.Sprintf("Smart filter disabled for %s", channel.name)))
+	default:
+		m.addMessage(formatSystemMessage("Usage: /filter [on|off]"))
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPort    = 6667
+	defaultTLSPort = 6697
+)
+
+// options are the command-line settings. Those left unset keep the value
+// from the config file.
+type options struct {
+	configPath string
+	profile    string
+	server     string
+	port       int
+	nick       string
+	channels   []string
+	tls        *bool // nil unless --tls or --no-tls was given
+	skipSetup  bool
+	version    bool
+}
+
+// stringList is a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseArgs reads the command line. Flags and irc:// or ircs:// URLs may
+// come in any order; a URL gives the server, port, TLS and channels as
+// the flags would.
+func parseArgs(args []string, output io.Writer) (*options, error) {
+	var (
+		opts     options
+		channels stringList
+		useTLS   bool
+		noTLS    bool
+	)
+	fs := flag.NewFlagSet("goirc", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.configPath, "config", "", "read and save the config at `path`")
+	fs.StringVar(&opts.profile, "profile", "", "use the config saved as `name` in the profiles directory")
+	fs.StringVar(&opts.server, "server", "", "connect to `host`, or host:port")
+	fs.IntVar(&opts.port, "port", 0, "server `port` (default 6697 with TLS, 6667 without)")
+	fs.StringVar(&opts.nick, "nick", "", "use `nick` as the nickname")
+	fs.Var(&channels, "channel", "join `channel` on connect; may be repeated")
+	fs.BoolVar(&useTLS, "tls", false, "connect with TLS")
+	fs.BoolVar(&noTLS, "no-tls", false, "connect without TLS")
+	fs.BoolVar(&opts.skipSetup, "skip-setup", false, "connect with the saved config instead of running the setup wizard")
+	fs.BoolVar(&opts.version, "version", false, "print the version and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: goirc [flags] [irc://host[:port]/#channel | ircs://...]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	// The flag package stops at the first argument that is not a flag, so
+	// pick out the URLs one at a time
+	var urls []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		urls = append(urls, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if useTLS && noTLS {
+		return nil, fmt.Errorf("--tls and --no-tls cannot be used together")
+	}
+	if useTLS || noTLS {
+		opts.tls = &useTLS
+	}
+	if opts.configPath != "" && opts.profile != "" {
+		return nil, fmt.Errorf("--config and --profile cannot be used together")
+	}
+	if opts.port < 0 || opts.port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", opts.port)
+	}
+	if len(urls) > 1 {
+		return nil, fmt.Errorf("only one server URL can be given, got %d", len(urls))
+	}
+	if len(urls) == 1 {
+		if err := opts.applyURL(urls[0]); err != nil {
+			return nil, err
+		}
+	}
+	opts.channels = append(opts.channels, channels...)
+	return &opts, nil
+}
+
+// applyURL takes the server, port, TLS, nick and channels from an IRC URL:
+// irc://[nick@]host[:port][/channel[,channel...]]. Channels may be written
+// with their # in the fragment (ircs://host/#go) or escaped in the path
+// (ircs://host/%23go); a bare name gets a # added.
+func (o *options) applyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid server URL %q: %w", raw, err)
+	}
+	var useTLS bool
+	switch strings.ToLower(u.Scheme) {
+	case "irc":
+	case "ircs":
+		useTLS = true
+	default:
+		return fmt.Errorf("invalid server URL %q: the scheme must be irc:// or ircs://", raw)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid server URL %q: no host", raw)
+	}
+	if o.server != "" {
+		return fmt.Errorf("give the server either as --server or as a URL, not both")
+	}
+
+	o.server = u.Hostname()
+	if p := u.Port(); p != "" {
+		port, err := strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid server URL %q: bad port %q", raw, p)
+		}
+		if o.port == 0 {
+			o.port = port
+		}
+	}
+	if o.tls == nil {
+		o.tls = &useTLS
+	}
+	if u.User != nil && o.nick == "" {
+		o.nick = u.User.Username()
+	}
+	for _, part := range []string{strings.TrimPrefix(u.Path, "/"), u.Fragment} {
+		for _, name := range strings.Split(part, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				o.channels = append(o.channels, name)
+			}
+		}
+	}
+	return nil
+}
+
+// loadConfig loads the config the options point at: the file given with
+// --config, a named profile, or the default config
+func (o *options) loadConfig() (*Config, error) {
+	switch {
+	case o.configPath != "":
+		return LoadConfigFile(o.configPath)
+	case o.profile != "":
+		if strings.ContainsAny(o.profile, `/\`) || o.profile == "." || o.profile == ".." {
+			return nil, fmt.Errorf("invalid profile name %q", o.profile)
+		}
+		configDir, err := GetConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		return LoadConfigFile(filepath.Join(configDir, "profiles", o.profile+".json"))
+	}
+	return LoadConfig()
+}
+
+// apply writes the options given over the primary network of a config
+func (o *options) apply(config *Config) {
+	irc := &config.IRC
+	if o.tls != nil {
+		irc.UseSSL = *o.tls
+	}
+	if o.server != "" || o.port != 0 {
+		host := o.server
+		if host == "" {
+			host = irc.Server
+		}
+		// A port given with the host wins over the one configured
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			host = h
+			if port, err := strconv.Atoi(p); err == nil && o.port == 0 {
+				irc.Port = port
+			}
+		} else if o.server != "" {
+			irc.Port = defaultPort
+			if irc.UseSSL {
+				irc.Port = defaultTLSPort
+			}
+		}
+		if o.port != 0 {
+			irc.Port = o.port
+		}
+		irc.Server = host
+		// Without --tls or --no-tls, guess from the port as the wizard does
+		if o.tls == nil && o.server != "" {
+			irc.UseSSL = irc.Port == defaultTLSPort
+		}
+	}
+	if o.nick != "" {
+		irc.Nick = o.nick
+	}
+	if len(o.channels) > 0 {
+		irc.Channels = nil
+		for _, channel := range o.channels {
+			if channel == "" {
+				continue
+			}
+			if !strings.ContainsAny(channel[:1], "#&+!") {
+				channel = "#" + channel
+			}
+			irc.Channels = append(irc.Channels, channel)
+		}
+	}
+}
+
+// newModel applies the options to a loaded config and builds the model,
+// finishing setup straight away when they say enough to connect. Setup
+// then never saw the overrides, so they are kept out of the config file.
+func (o *options) newModel(config *Config) (model, error) {
+	loaded := config.IRC
+	o.apply(config)
+	m := newModel(config)
+	if !o.connectNow() {
+		return m, nil
+	}
+	m.loadedIRC = &loaded
+	return m, m.finishSetup()
+}
+
+// connectNow reports whether the command line says enough to connect
+// without the setup wizard: a server, or an explicit --skip-setup
+func (o *options) connectNow() bool {
+	return o.skipSetup || o.server != ""
+}
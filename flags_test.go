@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Connecting from the command line must not rewrite the config file with
+// the server, nick and channels given there
+func TestCommandLineOverridesNotSaved(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := DefaultConfig()
+	config.FilePath = path
+	config.IRC.Server = "irc.saved.test"
+	config.IRC.Port = 6697
+	config.IRC.UseSSL = true
+	config.IRC.Nick = "saved"
+	config.IRC.Channels = []string{"#saved"}
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseArgs([]string{"--config", path, "--nick", "override", "irc://irc.cli.test/#cli"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err = opts.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := opts.newModel(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.events.close)
+	if got := m.config.IRC; got.Server != "irc.cli.test" || got.Nick != "override" || got.UseSSL {
+		t.Errorf("overrides not applied: %+v", got)
+	}
+
+	// A setting changed while running is still saved
+	m.config.Logging.Enabled = !m.config.Logging.Enabled
+	if err := m.saveConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	irc := saved.IRC
+	if irc.Server != "irc.saved.test" || irc.Port != 6697 || !irc.UseSSL || irc.Nick != "saved" || !slices.Equal(irc.Channels, []string{"#saved"}) {
+		t.Errorf("saved network = %+v, want the one loaded", irc)
+	}
+	if saved.Logging.Enabled != m.config.Logging.Enabled {
+		t.Error("logging change was not saved")
+	}
+}
+
+// /config reload reads the file given with --config, not the default one
+func TestConfigReloadUsesConfigPath(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "custom.json")
+	config := DefaultConfig()
+	config.FilePath = path
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseArgs([]string{"--config", path, "--server", "irc.test"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err = opts.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := opts.newModel(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.events.close)
+	if m.highlighter.matches("tester", "alice", "gophers unite") {
+		t.Fatal("highlighted before the word was configured")
+	}
+
+	config.Highlight.Words = []string{"gophers"}
+	if err := config.Save(); err != nil {
+		t.Fatal(err)
+	}
+	m.handleCommand("/config reload")
+	if m.config.FilePath != path {
+		t.Errorf("reloaded from %q, want %q", m.config.FilePath, path)
+	}
+	if !m.highlighter.matches("tester", "alice", "gophers unite") {
+		t.Error("highlight words not reloaded")
+	}
+}
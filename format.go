@@ -0,0 +1,346 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// mIRC formatting control codes
+const (
+	fmtBold          = '\x02'
+	fmtColor         = '\x03'
+	fmtHexColor      = '\x04'
+	fmtReset         = '\x0F'
+	fmtMonospace     = '\x11'
+	fmtReverse       = '\x16'
+	fmtItalic        = '\x1D'
+	fmtStrikethrough = '\x1E'
+	fmtUnderline     = '\x1F'
+)
+
+// mircColors are the hex values of mIRC colors 0-98; 99 means the default
+var mircColors = [99]string{
+	// 0-15, the classic palette
+	"#ffffff", "#000000", "#00007f", "#009300", "#ff0000", "#7f0000", "#9c009c", "#fc7f00",
+	"#ffff00", "#00fc00", "#009393", "#00ffff", "#0000fc", "#ff00ff", "#7f7f7f", "#d2d2d2",
+	// 16-98, the extended palette
+	"#470000", "#472100", "#474700", "#324700", "#004700", "#00472c", "#004747", "#002747", "#000047", "#2e0047", "#470047", "#47002a",
+	"#740000", "#743a00", "#747400", "#517400", "#007400", "#007449", "#007474", "#004074", "#000074", "#4b0074", "#740074", "#740045",
+	"#b50000", "#b56300", "#b5b500", "#7db500", "#00b500", "#00b571", "#00b5b5", "#0063b5", "#0000b5", "#7500b5", "#b500b5", "#b5006b",
+	"#ff0000", "#ff8c00", "#ffff00", "#b2ff00", "#00ff00", "#00ffa0", "#00ffff", "#008cff", "#0000ff", "#a500ff", "#ff00ff", "#ff0098",
+	"#ff5959", "#ffb459", "#ffff71", "#cfff60", "#6fff6f", "#65ffc9", "#6dffff", "#59b4ff", "#5959ff", "#c459ff", "#ff66ff", "#ff59bc",
+	"#ff9c9c", "#ffd39c", "#ffff9c", "#e2ff9c", "#9cff9c", "#9cffdb", "#9cffff", "#9cd3ff", "#9c9cff", "#dc9cff", "#ff9cff", "#ff94d3",
+	"#000000", "#131313", "#282828", "#363636", "#4d4d4d", "#656565", "#818181", "#9f9f9f", "#bcbcbc", "#e2e2e2", "#ffffff",
+}
+
+// textFormat is the formatting in effect for a run of text. Colors are
+// lipgloss colors; empty means the message's own color.
+type textFormat struct {
+	bold, italic, underline, strikethrough, reverse, monospace bool
+	fg, bg                                                     string
+}
+
+// span is a run of text sharing one format
+type span struct {
+	text   string
+	format textFormat
+}
+
+// parseFormatting splits text at mIRC formatting codes. The codes are
+// consumed and any other control characters are dropped, so the spans only
+// hold printable text.
+func parseFormatting(text string) []span {
+	var spans []span
+	var current textFormat
+	var run strings.Builder
+	flush := func() {
+		if run.Len() > 0 {
+			spans = append(spans, span{text: run.String(), format: current})
+			run.Reset()
+		}
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch r {
+		case fmtBold, fmtItalic, fmtUnderline, fmtStrikethrough, fmtReverse, fmtMonospace:
+			flush()
+			switch r {
+			case fmtBold:
+				current.bold = !current.bold
+			case fmtItalic:
+				current.italic = !current.italic
+			case fmtUnderline:
+				current.underline = !current.underline
+			case fmtStrikethrough:
+				current.strikethrough = !current.strikethrough
+			case fmtReverse:
+				current.reverse = !current.reverse
+			case fmtMonospace:
+				current.monospace = !current.monospace
+			}
+		case fmtReset:
+			flush()
+			current = textFormat{}
+		case fmtColor:
+			flush()
+			fg, n := readDigits(runes[i+1:])
+			i += n
+			if n == 0 {
+				// A bare color code resets both colors
+				current.fg, current.bg = "", ""
+				continue
+			}
+			current.fg = mircColor(fg)
+			// The comma only belongs to the code when a background follows
+			if i+2 < len(runes) && runes[i+1] == ',' && isASCIIDigit(runes[i+2]) {
+				bg, n := readDigits(runes[i+2:])
+				current.bg = mircColor(bg)
+				i += n + 1
+			}
+		case fmtHexColor:
+			flush()
+			fg, ok := readHex(runes[i+1:])
+			if !ok {
+				current.fg, current.bg = "", ""
+				continue
+			}
+			current.fg = fg
+			i += 6
+			if i+7 < len(runes) && runes[i+1] == ',' {
+				if bg, ok := readHex(runes[i+2:]); ok {
+					current.bg = bg
+					i += 7
+				}
+			}
+		default:
+			if r == '\n' || r == '\t' || !unicode.IsControl(r) {
+				run.WriteRune(r)
+			}
+		}
+	}
+	flush()
+	return spans
+}
+
+// stripFormatting removes formatting codes and other control characters
+func stripFormatting(text string) string {
+	if !strings.ContainsFunc(text, unicode.IsControl) {
+		return text
+	}
+	var out strings.Builder
+	for _, s := range parseFormatting(text) {
+		out.WriteString(s.text)
+	}
+	return out.String()
+}
+
+func isASCIIDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// readDigits reads the one or two digit color number at the start of runes
+func readDigits(runes []rune) (string, int) {
+	n := 0
+	for n < 2 && n < len(runes) && isASCIIDigit(runes[n]) {
+		n++
+	}
+	return string(runes[:n]), n
+}
+
+// readHex reads the RRGGBB color at the start of runes
+func readHex(runes []rune) (string, bool) {
+	if len(runes) < 6 {
+		return "", false
+	}
+	for _, r := range runes[:6] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return "", false
+		}
+	}
+	return "#" + strings.ToLower(string(runes[:6])), true
+}
+
+// mircColor looks up a color number; 99 and unknown numbers are the default
+func mircColor(number string) string {
+	n := 0
+	for _, r := range number {
+		n = n*10 + int(r-'0')
+	}
+	if n >= len(mircColors) {
+		return ""
+	}
+	return mircColors[n]
+}
+
+// apply adds the span's formatting on top of a message style
+func (f textFormat) apply(style lipgloss.Style) lipgloss.Style {
+	if f.bold {
+		style = style.Bold(true)
+	}
+	if f.italic {
+		style = style.Italic(true)
+	}
+	if f.underline {
+		style = style.Underline(true)
+	}
+	if f.strikethrough {
+		style = style.Strikethrough(true)
+	}
+	if f.reverse {
+		style = style.Reverse(true)
+	}
+	if f.fg != "" {
+		style = style.Foreground(lipgloss.Color(f.fg))
+	}
+	if f.bg != "" {
+		style = style.Background(lipgloss.Color(f.bg))
+	}
+	return style
+}
+
+// styledCell is one character of a message and the index of its span
+type styledCell struct {
+	r     rune
+	width int
+	span  int
+}
+
+// wrapSpans word-wraps formatted text to width and renders each line with
+// style plus the spans' formatting. Lines are wrapped before styling so
+// that no style carries over a line break.
+func wrapSpans(spans []span, width int, style lipgloss.Style) []string {
+	var lines [][]styledCell
+	var line []styledCell
+	lineWidth := 0
+	wrapped := false // The current line continues the one above
+	breakLine := func() {
+		// Spaces at a wrap point are not carried to the end of the line
+		for len(line) > 0 && line[len(line)-1].r == ' ' {
+			line = line[:len(line)-1]
+		}
+		lines = append(lines, line)
+		line, lineWidth = nil, 0
+		wrapped = true
+	}
+	addWord := func(word []styledCell, wordWidth int) {
+		if width > 0 && lineWidth > 0 && lineWidth+wordWidth > width {
+			breakLine()
+		}
+		for _, c := range word {
+			// Words longer than a line are split wherever they reach the edge
+			if width > 0 && lineWidth > 0 && lineWidth+c.width > width {
+				breakLine()
+			}
+			line = append(line, c)
+			lineWidth += c.width
+		}
+	}
+
+	var word []styledCell
+	wordWidth := 0
+	endWord := func() {
+		addWord(word, wordWidth)
+		word, wordWidth = nil, 0
+	}
+	for i, s := range spans {
+		for _, r := range strings.ReplaceAll(s.text, "\t", "    ") {
+			switch r {
+			case '\n':
+				endWord()
+				breakLine()
+				wrapped = false
+			case ' ':
+				endWord()
+				if width > 0 && lineWidth >= width {
+					breakLine()
+				} else if lineWidth > 0 || !wrapped {
+					line = append(line, styledCell{r: r, width: 1, span: i})
+					lineWidth++
+				}
+			default:
+				w := ansi.StringWidth(string(r))
+				word = append(word, styledCell{r: r, width: w, span: i})
+				wordWidth += w
+			}
+		}
+	}
+	endWord()
+	lines = append(lines, line)
+
+	styles := make(map[int]lipgloss.Style)
+	rendered := make([]string, len(lines))
+	for i, cells := range lines {
+		var out, run strings.Builder
+		for j, c := range cells {
+			run.WriteRune(c.r)
+			if j+1 < len(cells) && cells[j+1].span == c.span {
+				continue
+			}
+			spanStyle, ok := styles[c.span]
+			if !ok {
+				spanStyle = spans[c.span].format.apply(style)
+				styles[c.span] = spanStyle
+			}
+			out.WriteString(spanStyle.Render(run.String()))
+			run.Reset()
+		}
+		rendered[i] = out.String()
+	}
+	return rendered
+}
+
+// formatKeys are the keys that follow Ctrl+X and the codes they insert
+var formatKeys = map[string]rune{
+	"b": fmtBold,
+	"i": fmtItalic,
+	"u": fmtUnderline,
+	"s": fmtStrikethrough,
+	"r": fmtReverse,
+	"o": fmtReset,
+	"c": fmtColor, // The color numbers follow as typed: 4,1
+}
+
+// The input box drops control characters, so a code stands in it as its
+// Unicode control picture (U+2400 plus the code, e.g. ␂ for bold) until
+// the line is sent
+const controlPictures = 0x2400
+
+// expandFormatting turns the codes inserted with Ctrl+X back into mIRC
+// control characters. Everything typed is sent as it is.
+func expandFormatting(input string) string {
+	return strings.Map(func(r rune) rune {
+		if code := r - controlPictures; code >= 0 && isFormatCode(code) {
+			return code
+		}
+		return r
+	}, input)
+}
+
+func isFormatCode(r rune) bool {
+	for _, code := range formatKeys {
+		if r == code {
+			return true
+		}
+	}
+	return false
+}
+
+// handleFormatKey finishes a Ctrl+X chord by inserting the code for the
+// key pressed. Unknown keys just cancel the chord.
+func (m *model) handleFormatKey(msg tea.KeyMsg) {
+	m.formatChord = false
+	if code, ok := formatKeys[msg.String()]; ok {
+		m.textarea.InsertRune(controlPictures + code)
+	}
+}
+
+// formatChordPrompt is shown in the help line while a chord is pending
+func formatChordPrompt() string {
+	return "Format: b bold, i italic, u underline, s strikethrough, r reverse, c color (then e.g. 4,1), o reset - any other key cancels"
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Per-channel notification choices for ChannelSettings.Notify
+const (
+	notifyDefault = ""        // Use the highlight settings
+	notifyOff     = "none"    // Never alert
+	notifyBell    = "bell"    // Terminal bell only
+	notifyDesktop = "desktop" // Desktop notification only
+	notifyAll     = "all"     // Bell and desktop notification
+)
+
+// Desktop notification escape sequences for HighlightConfig.Desktop
+const (
+	desktopOSC9   = "osc9"   // iTerm2, kitty, WezTerm, Windows Terminal
+	desktopOSC777 = "osc777" // urxvt, foot, VTE-based terminals
+)
+
+// notifyLevel is the most important thing that happened in a buffer since
+// it was last viewed
+type notifyLevel int
+
+const (
+	levelNone      notifyLevel = iota
+	levelActivity              // Joins, parts, modes and other events
+	levelMessage               // Someone spoke
+	levelHighlight             // Someone mentioned us, or wrote to us privately
+)
+
+// alertHold is how long an alert stays ahead of the screen: long enough
+// for the renderer to draw it in at least one frame
+const alertHold = 100 * time.Millisecond
+
+// alertDoneMsg takes an alert off the screen once it has been drawn
+type alertDoneMsg struct{ gen int }
+
+// highlighter decides which messages mention us
+type highlighter struct {
+	words        []string
+	patterns     []*regexp.Regexp
+	exclude      []*regexp.Regexp
+	excludeNicks map[string]bool
+}
+
+// newHighlighter compiles the highlight settings. Patterns that do not
+// compile are left out and the first such error is returned.
+func newHighlighter(cfg HighlightConfig) (*highlighter, error) {
+	h := &highlighter{excludeNicks: make(map[string]bool)}
+	var firstErr error
+	compile := func(pattern string) *regexp.Regexp {
+		re, err := regexp.Compile(pattern)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("invalid highlight pattern %q: %w", pattern, err)
+		}
+		return re
+	}
+
+	for _, word := range cfg.Words {
+		if word = strings.TrimSpace(word); word != "" {
+			h.words = append(h.words, word)
+		}
+	}
+	for _, pattern := range cfg.Patterns {
+		if re := compile(pattern); re != nil {
+			h.patterns = append(h.patterns, re)
+		}
+	}
+	for _, pattern := range cfg.Exclude {
+		if re := compile(pattern); re != nil {
+			h.exclude = append(h.exclude, re)
+		}
+	}
+	for _, nick := range cfg.ExcludeNicks {
+		h.excludeNicks[strings.ToLower(nick)] = true
+	}
+	return h, firstErr
+}
+
+// matches reports whether text from sender mentions ownNick or any of the
+// configured words and patterns, and is not excluded
+func (h *highlighter) matches(ownNick, sender, text string) bool {
+	if strings.EqualFold(sender, ownNick) || h.excludeNicks[strings.ToLower(sender)] {
+		return false
+	}
+	for _, re := range h.exclude {
+		if re.MatchString(text) {
+			return false
+		}
+	}
+	if ownNick != "" && containsWord(text, ownNick) {
+		return true
+	}
+	for _, word := range h.words {
+		if containsWord(text, word) {
+			return true
+		}
+	}
+	for _, re := range h.patterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWordRune reports whether r can be part of a nick or word, so that
+// "bob" matches in "bob: hi" but not in "bobby" or "[bob]x"
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-[]\\`^{|}", r)
+}
+
+// containsWord finds word in text, ignoring case, with no word characters
+// directly around it
+func containsWord(text, word string) bool {
+	lowerText := []rune(strings.ToLower(text))
+	lowerWord := []rune(strings.ToLower(word))
+	n := len(lowerWord)
+	for i := 0; i+n <= len(lowerText); i++ {
+		if string(lowerText[i:i+n]) != string(lowerWord) {
+			continue
+		}
+		before := i == 0 || !isWordRune(lowerText[i-1])
+		after := i+n == len(lowerText) || !isWordRune(lowerText[i+n])
+		if before && after {
+			return true
+		}
+	}
+	return false
+}
+
+// isHighlight checks a message from sender against our nick on its network
+// and the highlight settings
+func (m *model) isHighlight(net *network, sender, text string) bool {
+	if m.highlighter == nil || net == nil {
+		return false
+	}
+	return m.highlighter.matches(net.nick, sender, stripFormatting(text))
+}
+
+// alert rings the bell and/or sends a desktop notification for a highlight
+// or a private message, as configured globally and for the channel
+func (m *model) alert(network, key string, msg Message) tea.Cmd {
+	channel, exists := m.channels[key]
+	private := exists && channel.isQuery && msg.Kind != KindOwn
+	if !msg.Highlight && !private {
+		return nil
+	}
+
+	bell, desktop := m.config.Highlight.Bell, m.config.Highlight.Desktop != ""
+	title := msg.Sender
+	if exists {
+		title = channel.name
+		if net := m.network(network); net != nil {
+			switch net.config.Settings(channel.name).Notify {
+			case notifyOff:
+				return nil
+			case notifyBell:
+				bell, desktop = true, false
+			case notifyDesktop:
+				bell, desktop = false, true
+			case notifyAll:
+				bell, desktop = true, true
+			}
+		}
+	}
+	if !bell && !desktop {
+		return nil
+	}
+
+	protocol := m.config.Highlight.Desktop
+	if protocol == "" {
+		protocol = desktopOSC777
+	}
+	// The renderer writes the sequences along with the next frame; they
+	// take up no room on the screen
+	var out strings.Builder
+	if bell {
+		out.WriteString("\a")
+	}
+	if desktop {
+		out.WriteString(desktopNotification(protocol, title, stripFormatting(msg.body())))
+	}
+	m.pendingAlert = out.String()
+	m.alertGen++
+	gen := m.alertGen
+	return tea.Tick(alertHold, func(time.Time) tea.Msg {
+		return alertDoneMsg{gen: gen}
+	})
+}
+
+// desktopNotification builds the escape sequence that asks the terminal to
+// show a notification
+func desktopNotification(protocol, title, body string) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f || r == ';' {
+				return ' '
+			}
+			return r
+		}, s)
+	}
+	title, body = clean(title), clean(body)
+	if protocol == desktopOSC9 {
+		return "\x1b]9;" + title + ": " + body + "\x07"
+	}
+	return "\x1b]777;notify;" + title + ";" + body + "\x07"
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	historyLimit    = 500 // Lines kept per buffer and globally
+	historyFileName = "history.json"
+)
+
+// sensitiveWords mark service commands whose arguments are credentials
+var sensitiveWords = []string{"identify", "register", "auth", "login", "ghost", "regain", "recover", "setpass", "pass", "password", "passwd"}
+
+// serviceNicks take commands as plain messages, so lines typed into their
+// query buffers are checked like /msg to them. Q and X are QuakeNet's and
+// Undernet's.
+var serviceNicks = []string{"nickserv", "chanserv", "memoserv", "hostserv", "operserv", "botserv", "authserv", "q", "x"}
+
+// inputHistory holds sent lines, newest last, per buffer and across all buffers
+type inputHistory struct {
+	Global  []string            `json:"global"`
+	Buffers map[string][]string `json:"buffers"`
+
+	path string
+}
+
+// historyBrowse is an Up/Down walk through one history list
+type historyBrowse struct {
+	lines []string // Snapshot being walked
+	pos   int      // Index into lines; len(lines) is the draft
+	draft string   // What was typed before browsing started
+}
+
+// historySearch is an incremental reverse search (Ctrl+R) of the global history
+type historySearch struct {
+	query string
+	match int // Index into the global history, -1 when nothing matches
+	draft string
+}
+
+// loadHistory reads the saved history, starting empty if there is none
+func loadHistory() *inputHistory {
+	h := &inputHistory{Buffers: make(map[string][]string)}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return h
+	}
+	h.path = filepath.Join(configDir, historyFileName)
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return h
+	}
+	if err := json.Unmarshal(data, h); err != nil || h.Buffers == nil {
+		h.Buffers = make(map[string][]string)
+	}
+	return h
+}
+
+// save writes the history next to the config file, readable only by us
+func (h *inputHistory) save() error {
+	if h.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, data, 0600)
+}
+
+// add records a line sent from a buffer, named target, and globally.
+// Lines carrying passwords are never recorded.
+func (h *inputHistory) add(buffer, target, line string) bool {
+	if isSensitive(target, line) {
+		return false
+	}
+	h.Global = appendHistory(h.Global, line)
+	if buffer != "" {
+		h.Buffers[buffer] = appendHistory(h.Buffers[buffer], line)
+	}
+	return true
+}
+
+// appendHistory adds a line, skipping immediate repeats and trimming to historyLimit
+func appendHistory(lines []string, line string) []string {
+	if len(lines) > 0 && lines[len(lines)-1] == line {
+		return lines
+	}
+	lines = append(lines, line)
+	if len(lines) > historyLimit {
+		lines = append([]string(nil), lines[len(lines)-historyLimit:]...)
+	}
+	return lines
+}
+
+// isSensitive reports whether a line typed into the target's buffer looks
+// like it carries a password, e.g. /msg NickServ IDENTIFY hunter2, /oper
+// admin secret, or IDENTIFY hunter2 in a query with NickServ
+func isSensitive(target, line string) bool {
+	fields := strings.Fields(strings.ToLower(line))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "/oper", "/pass":
+		return true
+	case "/msg", "/query", "/q", "/quote", "/raw", "/ns", "/nickserv", "/cs", "/chanserv":
+		for _, field := range fields[1:] {
+			for _, word := range sensitiveWords {
+				if field == word {
+					return true
+				}
+			}
+		}
+	}
+	return slices.Contains(serviceNicks, strings.ToLower(target)) && slices.Contains(sensitiveWords, fields[0])
+}
+
+// maskCredentials hides everything after the first sensitive word of a
+// message, e.g. IDENTIFY hunter2 becomes IDENTIFY ****, so the echo of a
+// sent line and the chat log never hold the password
+func maskCredentials(text string) string {
+	fields := strings.Fields(text)
+	for i, field := range fields {
+		if slices.Contains(sensitiveWords, strings.ToLower(field)) {
+			for j := i + 1; j < len(fields); j++ {
+				fields[j] = "****"
+			}
+			return strings.Join(fields, " ")
+		}
+	}
+	return text
+}
+
+// recordHistory stores a sent line and persists the history
+func (m *model) recordHistory(line string) {
+	if m.history.add(m.currentBuffer, m.currentTarget(), line) {
+		if err := m.history.save(); err != nil {
+			m.logger.LogError("Failed to save input history: %v", err)
+		}
+	}
+}
+
+// browseHistory steps through the current buffer's history, or the global
+// one, replacing the input; older moves back in time
+func (m *model) browseHistory(global, older bool) {
+	if m.browse == nil {
+		lines := m.history.Buffers[m.currentBuffer]
+		if global {
+			lines = m.history.Global
+		}
+		if len(lines) == 0 {
+			return
+		}
+		m.browse = &historyBrowse{lines: lines, pos: len(lines), draft: m.textarea.Value()}
+	}
+
+	b := m.browse
+	switch {
+	case older && b.pos > 0:
+		b.pos--
+	case !older && b.pos < len(b.lines):
+		b.pos++
+	default:
+		return
+	}
+
+	if b.pos == len(b.lines) {
+		m.textarea.SetValue(b.draft)
+		m.browse = nil
+		return
+	}
+	m.textarea.SetValue(b.lines[b.pos])
+}
+
+// startHistorySearch begins Ctrl+R, or moves to the next older match
+func (m *model) startHistorySearch() {
+	if m.search == nil {
+		m.search = &historySearch{match: -1, draft: m.textarea.Value()}
+		return
+	}
+	if m.search.query != "" {
+		m.findHistoryMatch(m.search.match - 1)
+	}
+}
+
+// findHistoryMatch looks for the query in the global history from index
+// from backwards, showing the match in the input
+func (m *model) findHistoryMatch(from int) {
+	s := m.search
+	query := strings.ToLower(s.query)
+	if from >= len(m.history.Global) {
+		from = len(m.history.Global) - 1
+	}
+	for i := from; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(m.history.Global[i]), query) {
+			s.match = i
+			m.textarea.SetValue(m.history.Global[i])
+			return
+		}
+	}
+	// Keep showing the last match when there are no older ones
+	if s.match < 0 || !strings.Contains(strings.ToLower(m.history.Global[s.match]), query) {
+		s.match = -1
+		m.textarea.SetValue(s.draft)
+	}
+}
+
+// handleHistorySearchKey drives the search prompt; it reports whether the key
+// was consumed
+func (m *model) handleHistorySearchKey(msg tea.KeyMsg) bool {
+	s := m.search
+	switch msg.Type {
+	case tea.KeyCtrlR:
+		m.startHistorySearch()
+	case tea.KeyEnter:
+		// Accept the match into the input without sending it
+		m.search = nil
+	case tea.KeyEsc, tea.KeyCtrlG:
+		m.textarea.SetValue(s.draft)
+		m.search = nil
+	case tea.KeyBackspace:
+		if s.query != "" {
+			runes := []rune(s.query)
+			s.query = string(runes[:len(runes)-1])
+			if s.query == "" {
+				s.match = -1
+				m.textarea.SetValue(s.draft)
+			} else {
+				m.findHistoryMatch(len(m.history.Global) - 1)
+			}
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		s.query += string(msg.Runes)
+		m.findHistoryMatch(len(m.history.Global) - 1)
+	default:
+		// Any other key accepts the match and is then handled as usual
+		m.search = nil
+		return false
+	}
+	return true
+}
+
+// historySearchPrompt is shown in place of the help line while searching
+func (m *model) historySearchPrompt() string {
+	if m.search.query != "" && m.search.match < 0 {
+		return "(failed reverse-i-search)`" + m.search.query + "'"
+	}
+	return "(reverse-i-search)`" + m.search.query + "' - Ctrl+R older, Enter accept, Esc cancel"
+}
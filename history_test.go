@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestIsSensitive(t *testing.T) {
+	tests := []struct {
+		target, line string
+		want         bool
+	}{
+		{"#test", "/msg NickServ IDENTIFY hunter2", true},
+		{"#test", "/ns register hunter2 me@example.com", true},
+		{"#test", "/msg NickServ SET PASS hunter2", true},
+		{"#test", "/quote PASS hunter2", true},
+		{"#test", "/raw pass hunter2", true},
+		{"#test", "/pass hunter2", true},
+		{"#test", "/oper admin hunter2", true},
+		{"NickServ", "identify hunter2", true},
+		{"nickserv", "REGISTER pw me@example.com", true},
+		{"ChanServ", "identify #test hunter2", true},
+		{"NickServ", "help identify", false},
+		{"alice", "identify hunter2", false},
+		{"#test", "register now!", false},
+		{"#test", "/msg alice hello", false},
+		{"#test", "/join #passwords", false},
+		{"#test", "the password is in the wiki", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := isSensitive(tt.target, tt.line); got != tt.want {
+			t.Errorf("isSensitive(%q, %q) = %v, want %v", tt.target, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSensitiveLinesNotRecorded(t *testing.T) {
+	h := &inputHistory{Buffers: make(map[string][]string)}
+	if h.add("net/#test", "#test", "/quote PASS hunter2") {
+		t.Error("PASS line recorded")
+	}
+	if h.add("net/nickserv", "NickServ", "identify hunter2") {
+		t.Error("IDENTIFY in the NickServ query recorded")
+	}
+	if !h.add("net/#test", "#test", "hello") || len(h.Global) != 1 || len(h.Buffers["net/#test"]) != 1 {
+		t.Errorf("history = %+v", h)
+	}
+}
+
+func TestMaskCredentials(t *testing.T) {
+	tests := map[string]string{
+		"IDENTIFY hunter2":           "IDENTIFY ****",
+		"identify tester hunter2":    "identify **** ****",
+		"SET PASS hunter2":           "SET PASS ****",
+		"REGISTER pw me@example.com": "REGISTER **** ****",
+		"hello there":                "hello there",
+	}
+	for text, want := range tests {
+		if got := maskCredentials(text); got != want {
+			t.Errorf("maskCredentials(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
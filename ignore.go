@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"goirc/session"
+)
+
+// Ignore levels, the kinds of events an ignore entry hides
+const (
+	ignoreMsgs    = "msgs"    // Messages and actions
+	ignoreNotices = "notices" // Notices
+	ignoreCTCPs   = "ctcps"   // CTCP requests and replies; no request is answered, VERSION and PING included
+	ignoreJoins   = "joins"
+	ignoreParts   = "parts"
+	ignoreQuits   = "quits"
+	ignoreNicks   = "nicks" // Nick changes
+	ignoreAll     = "all"
+)
+
+var ignoreLevels = []string{ignoreMsgs, ignoreNotices, ignoreCTCPs, ignoreJoins, ignoreParts, ignoreQuits, ignoreNicks, ignoreAll}
+
+// ignoreList holds the ignore entries. Sessions check it on their own
+// goroutines while commands change it, so it is shared behind a lock.
+type ignoreList struct {
+	mu      sync.Mutex
+	entries []IgnoreEntry
+}
+
+func newIgnoreList(entries []IgnoreEntry) *ignoreList {
+	l := &ignoreList{}
+	l.set(entries)
+	return l
+}
+
+// set replaces the entries, e.g. after the config is reloaded
+func (l *ignoreList) set(entries []IgnoreEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append([]IgnoreEntry(nil), entries...)
+}
+
+// list returns the entries that have not expired, dropping the rest
+func (l *ignoreList) list() []IgnoreEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	live := l.entries[:0]
+	for _, entry := range l.entries {
+		if !entry.expired(now) {
+			live = append(live, entry)
+		}
+	}
+	l.entries = live
+	return append([]IgnoreEntry(nil), live...)
+}
+
+// add stores an entry, replacing one with the same mask. It reports
+// whether an entry was replaced.
+func (l *ignoreList) add(entry IgnoreEntry) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, existing := range l.entries {
+		if strings.EqualFold(existing.Mask, entry.Mask) {
+			l.entries[i] = entry
+			return true
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return false
+}
+
+// remove deletes the entry with the given mask, or the given position as
+// numbered by /ignores
+func (l *ignoreList) remove(maskOrNumber string) (IgnoreEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	index := -1
+	if n, err := strconv.Atoi(maskOrNumber); err == nil && n >= 1 && n <= len(l.entries) {
+		index = n - 1
+	} else {
+		mask := normalizeMask(maskOrNumber)
+		for i, entry := range l.entries {
+			if strings.EqualFold(entry.Mask, mask) {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		return IgnoreEntry{}, false
+	}
+	entry := l.entries[index]
+	l.entries = append(l.entries[:index], l.entries[index+1:]...)
+	return entry, true
+}
+
+// ignored reports whether an event of the given level from nick!ident@host
+// is hidden by any live entry
+func (l *ignoreList) ignored(nick, ident, host, level string) bool {
+	if l == nil || nick == "" {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	hostmask := nick + "!" + ident + "@" + host
+	for _, entry := range l.entries {
+		if !entry.expired(now) && entry.covers(level) && wildcardMatch(entry.Mask, hostmask) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredUser checks the sender of a session event
+func (l *ignoreList) ignoredUser(from session.User, level string) bool {
+	return l.ignored(from.Nick, from.Ident, from.Host, level)
+}
+
+func (e IgnoreEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+// covers reports whether the entry hides events of a level; no levels
+// means all of them
+func (e IgnoreEntry) covers(level string) bool {
+	if len(e.Levels) == 0 {
+		return true
+	}
+	for _, l := range e.Levels {
+		if l == ignoreAll || l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// describe is the entry as shown by /ignores
+func (e IgnoreEntry) describe() string {
+	levels := ignoreAll
+	if len(e.Levels) > 0 {
+		levels = strings.Join(e.Levels, ",")
+	}
+	text := fmt.Sprintf("%s [%s]", e.Mask, levels)
+	if !e.Expires.IsZero() {
+		text += fmt.Sprintf(" for %s more", time.Until(e.Expires).Round(time.Second))
+	}
+	return text
+}
+
+// normalizeMask fills in the missing parts of a mask, so "bob" becomes
+// "bob!*@*" and "*@host" becomes "*!*@host"
+func normalizeMask(mask string) string {
+	hasBang, hasAt := strings.Contains(mask, "!"), strings.Contains(mask, "@")
+	switch {
+	case !hasBang && !hasAt:
+		return mask + "!*@*"
+	case !hasBang:
+		return "*!" + mask
+	case !hasAt:
+		return mask + "@*"
+	}
+	return mask
+}
+
+// wildcardMatch matches s against a pattern where * is any run of
+// characters and ? any single one, ignoring case. Brackets are literal
+// since they are common in nicks.
+func wildcardMatch(pattern, s string) bool {
+	p, t := []rune(strings.ToLower(pattern)), []rune(strings.ToLower(s))
+	pi, ti := 0, 0
+	star, mark := -1, 0
+	for ti < len(t) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == t[ti]):
+			pi++
+			ti++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ti
+			pi++
+		case star >= 0:
+			// Let the last star swallow one more character
+			pi = star + 1
+			mark++
+			ti = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// parseIgnoreLevels checks level names, e.g. "msgs,notices" or "msgs ctcps"
+func parseIgnoreLevels(args []string) ([]string, error) {
+	var levels []string
+	for _, arg := range args {
+		for _, level := range strings.Split(strings.ToLower(arg), ",") {
+			if level == "" {
+				continue
+			}
+			if !isIgnoreLevel(level) {
+				return nil, fmt.Errorf("unknown ignore level %q (use %s)", level, strings.Join(ignoreLevels, ", "))
+			}
+			if level == ignoreAll {
+				return nil, nil
+			}
+			levels = append(levels, level)
+		}
+	}
+	return levels, nil
+}
+
+func isIgnoreLevel(level string) bool {
+	for _, l := range ignoreLevels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIgnoreDuration reads an expiry such as 30m, 2h or 7d
+func parseIgnoreDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// saveIgnores copies the live entries into the config and saves it
+func (m *model) saveIgnores() {
+	m.config.Ignores = m.ignores.list()
+	m.saveConfig()
+}
+
+// handleIgnoreCommand runs /ignore [-time <duration>] <mask> [levels]
+func (m *model) handleIgnoreCommand(args []string) {
+	var expires time.Time
+	if len(args) >= 2 && strings.EqualFold(args[0], "-time") {
+		d, err := parseIgnoreDuration(args[1])
+		if err != nil {
+			m.addMessage(formatErrorMessage(err.Error()))
+			return
+		}
+		expires = time.Now().Add(d)
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		m.addMessage(formatSystemMessage("Usage: /ignore [-time <30m|2h|7d>] <nick|mask> [" + strings.Join(ignoreLevels, ",") + "]"))
+		return
+	}
+	levels, err := parseIgnoreLevels(args[1:])
+	if err != nil {
+		m.addMessage(formatErrorMessage(err.Error()))
+		return
+	}
+
+	entry := IgnoreEntry{Mask: normalizeMask(args[0]), Levels: levels, Expires: expires}
+	verb := "Ignoring"
+	if m.ignores.add(entry) {
+		verb = "Now ignoring"
+	}
+	m.saveIgnores()
+	m.addMessage(formatSystemMessage(verb + " " + entry.describe()))
+}
+
+// showIgnores lists the entries, numbered for /unignore
+func (m *model) showIgnores() {
+	entries := m.ignores.list()
+	if len(entries) == 0 {
+		m.addMessage(formatSystemMessage("Ignore list is empty"))
+		return
+	}
+	m.addMessage(formatSystemMessage(fmt.Sprintf("Ignoring (%d):", len(entries))))
+	for i, entry := range entries {
+		m.addMessage(formatSystemMessage(fmt.Sprintf("%d. %s", i+1, entry.describe())))
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"goirc/session"
+)
+
+// testClient runs the real model against a fake server the way the
+// Bubble Tea loop would: commands run on their own goroutines and their
+// messages come back to Update on the test goroutine.
+type testClient struct {
+	t    *testing.T
+	m    model
+	msgs chan tea.Msg
+	done chan struct{}
+}
+
+// startClient configures a client for the fake server and starts
+// connecting, as giving a server on the command line does
+func startClient(t *testing.T, srv *fakeServer, useTLS bool, channels ...string) *testClient {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	next, _ := initialModel().Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	return runClient(t, next.(model), srv, useTLS, channels...)
+}
+
+// runClient points an existing model at the fake server and starts it
+func runClient(t *testing.T, m model, srv *fakeServer, useTLS bool, channels ...string) *testClient {
+	t.Helper()
+	m.config.FilePath = filepath.Join(t.TempDir(), "config.json")
+	if srv.roots != nil {
+		connect := newSession
+		newSession = func(cfg session.Config) *session.Session {
+			cfg.RootCAs = srv.roots
+			return connect(cfg)
+		}
+		t.Cleanup(func() { newSession = connect })
+	}
+	m.config.IRC = IRCConfig{
+		Server:   "127.0.0.1",
+		Port:     srv.port(),
+		Nick:     "tester",
+		Channels: channels,
+		UseSSL:   useTLS,
+	}
+	if err := m.finishSetup(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testClient{t: t, m: m, msgs: make(chan tea.Msg, 64), done: make(chan struct{})}
+	t.Cleanup(func() {
+		close(c.done)
+		c.m.events.close()
+		for _, net := range c.m.networks {
+			if net.session != nil {
+				net.session.Close()
+			}
+		}
+	})
+	c.run(c.m.Init())
+	return c
+}
+
+// run executes a command in the background and queues its message. The
+// commands of a batch run side by side, as in Bubble Tea.
+func (c *testClient) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	go func() {
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, cmd := range batch {
+				c.run(cmd)
+			}
+			return
+		}
+		if msg != nil {
+			select {
+			case c.msgs <- msg:
+			case <-c.done:
+			}
+		}
+	}()
+}
+
+// handle applies a message, running any command it returns
+func (c *testClient) handle(msg tea.Msg) {
+	next, cmd := c.m.Update(msg)
+	c.m = next.(model)
+	c.run(cmd)
+}
+
+// waitFor processes messages until cond holds
+func (c *testClient) waitFor(what string, cond func() bool) {
+	c.t.Helper()
+	timeout := time.After(fakeTimeout)
+	for !cond() {
+		select {
+		case msg := <-c.msgs:
+			c.handle(msg)
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s\n%s", what, c.view())
+		}
+	}
+}
+
+// waitForLine waits until a buffer has a line containing text
+func (c *testClient) waitForLine(buffer, text string) {
+	c.t.Helper()
+	c.waitFor(buffer+" to show "+text, func() bool {
+		return strings.Contains(c.bufferText(buffer), text)
+	})
+}
+
+// waitForView waits until the screen shows text
+func (c *testClient) waitForView(text string) {
+	c.t.Helper()
+	c.waitFor("the view to show "+text, func() bool {
+		return strings.Contains(c.view(), text)
+	})
+}
+
+// bufferText is the plain text of a buffer's lines, one per line
+func (c *testClient) bufferText(name string) string {
+	channel, ok := c.m.findBuffer(c.m.networkOrder[0], name)
+	if !ok {
+		return ""
+	}
+	var lines []string
+	for _, msg := range channel.messages.all() {
+		line := msg.Text
+		if msg.Sender != "" && (msg.Kind == KindChat || msg.Kind == KindOwn) {
+			line = "<" + msg.Sender + "> " + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// view is the screen without styling
+func (c *testClient) view() string {
+	return ansi.Strip(c.m.View())
+}
+
+// input types a line into the input box and presses Enter
+func (c *testClient) input(text string) {
+	c.m.textarea.SetValue(text)
+	c.handle(tea.KeyMsg{Type: tea.KeyEnter})
+}
+
+// waitForConnect processes messages until registration completes, which
+// is when the client sends its joins
+func (c *testClient) waitForConnect() {
+	c.t.Helper()
+	c.waitFor("registration", func() bool { return c.network().connected })
+}
+
+func (c *testClient) network() *network {
+	return c.m.network(c.m.networkOrder[0])
+}
+
+// connectToChannel brings a client up on #test with a few members
+func connectToChannel(t *testing.T, srv *fakeServer, useTLS bool) (*testClient, *fakeConn) {
+	t.Helper()
+	c := startClient(t, srv, useTLS, "#test")
+	conn := srv.accept()
+	conn.register()
+	c.waitForConnect()
+	conn.acceptJoin("#test", "Testing all day", "alice", "@bob", "+carol")
+	c.waitFor("the #test member list", func() bool {
+		channel, ok := c.m.findBuffer(c.network().name, "#test")
+		return ok && channel.joined && len(c.m.members(channel)) == 4
+	})
+	return c, conn
+}
+
+func TestRegistrationAndNames(t *testing.T) {
+	c, _ := connectToChannel(t, newFakeServer(t, nil), false)
+
+	if !c.network().connected {
+		t.Fatal("network not marked connected")
+	}
+	if got := c.network().nick; got != "tester" {
+		t.Errorf("nick = %q, want tester", got)
+	}
+	channel, _ := c.m.findBuffer(c.network().name, "#test")
+	if got, want := strings.Join(c.m.sortedMembers(channel), ","), "@bob,+carol, alice, tester"; got != want {
+		t.Errorf("members = %q, want %q", got, want)
+	}
+	c.waitForLine("#test", "Testing all day")
+
+	view := c.view()
+	for _, want := range []string{"#test", "Testing all day", "@bob", "+carol"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view is missing %q:\n%s", want, view)
+		}
+	}
+}
+
+// The nick list and topic are the model's copies, kept up to date from the
+// session's events; drawing them does not ask the session
+func TestMembersAndTopicFollowEvents(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+	conn.from("dave", "JOIN #test")
+	conn.from("bob", "MODE #test +v dave")
+	conn.from("bob", "TOPIC #test :Testing all night")
+	c.waitFor("dave's voice and the new topic", func() bool {
+		channel, _ := c.m.findBuffer(c.network().name, "#test")
+		return channel.members["dave"] == "+" && channel.topic == "Testing all night"
+	})
+
+	net := c.network()
+	sess := net.session
+	net.session = nil
+	view := c.view()
+	net.session = sess
+	for _, want := range []string{"USERS 5", "+dave", "@bob", "Testing all night"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view is missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestPrivmsg(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	conn.from("alice", "PRIVMSG #test :hello from alice")
+	c.waitForLine("#test", "<alice> hello from alice")
+	if view := c.view(); !strings.Contains(view, "hello from alice") {
+		t.Errorf("view is missing alice's message:\n%s", view)
+	}
+
+	c.input("hi alice")
+	if params := conn.expect("PRIVMSG"); params[0] != "#test" || params[1] != "hi alice" {
+		t.Errorf("client sent PRIVMSG %q", params)
+	}
+	if text := c.bufferText("#test"); !strings.Contains(text, "<tester> hi alice") {
+		t.Errorf("own message not shown:\n%s", text)
+	}
+	channel, _ := c.m.findBuffer(c.m.networkOrder[0], "#test")
+	if msgs := channel.messages.all(); msgs[len(msgs)-1].Kind != KindOwn {
+		t.Errorf("own message stored as %q", msgs[len(msgs)-1].Kind)
+	}
+	if view := c.view(); !strings.Contains(view, "<tester> hi alice") {
+		t.Errorf("own message drawn without its sender:\n%s", view)
+	}
+
+	conn.from("bob", "PRIVMSG tester :psst")
+	c.waitForLine("bob", "<bob> psst")
+}
+
+// A message to a channel we are not in has no buffer but is still logged
+func TestMsgWithoutBufferIsLogged(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	c.input("/msg #elsewhere hello there")
+	if params := conn.expect("PRIVMSG"); params[0] != "#elsewhere" {
+		t.Errorf("client sent PRIVMSG %q", params)
+	}
+	logged, err := c.m.chatLog.tail(c.network().name, "#elsewhere", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 1 || logged[0].Text != "hello there" || logged[0].Kind != KindOwn {
+		t.Errorf("logged %+v", logged)
+	}
+}
+
+// Passwords sent to services reach the server but not the screen or the
+// chat log, which is replayed on the next start
+func TestCredentialsNotLogged(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	c.input("/msg NickServ IDENTIFY hunter2")
+	if params := conn.expect("PRIVMSG"); params[1] != "IDENTIFY hunter2" {
+		t.Errorf("client sent PRIVMSG %q", params)
+	}
+	c.input("/query NickServ REGISTER swordfish me@example.com")
+	if params := conn.expect("PRIVMSG"); params[1] != "REGISTER swordfish me@example.com" {
+		t.Errorf("client sent PRIVMSG %q", params)
+	}
+	if text := c.bufferText("NickServ"); !strings.Contains(text, "<tester> REGISTER **** ****") {
+		t.Errorf("masked echo not shown:\n%s", text)
+	}
+	// Typed into the query, which /query switched to
+	c.input("identify tester opensesame")
+	if params := conn.expect("PRIVMSG"); params[0] != "NickServ" || params[1] != "identify tester opensesame" {
+		t.Errorf("client sent PRIVMSG %q", params)
+	}
+	if history := strings.Join(c.m.history.Global, "\n"); strings.Contains(history, "opensesame") {
+		t.Errorf("history holds the password:\n%s", history)
+	}
+
+	var logged strings.Builder
+	err := filepath.WalkDir(c.m.config.GetChatLogDir(), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		logged.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "IDENTIFY ****") {
+		t.Errorf("IDENTIFY not logged:\n%s", logged.String())
+	}
+	for _, secret := range []string{"hunter2", "swordfish", "me@example.com", "opensesame"} {
+		if strings.Contains(logged.String(), secret) {
+			t.Errorf("chat log holds %q:\n%s", secret, logged.String())
+		}
+		if strings.Contains(c.view(), secret) {
+			t.Errorf("screen shows %q", secret)
+		}
+	}
+}
+
+func TestFormattingInput(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	// Percent signs are plain text
+	c.input("100%b sure, 50%c off")
+	if params := conn.expect("PRIVMSG"); params[1] != "100%b sure, 50%c off" {
+		t.Errorf("plain text was rewritten: %q", params[1])
+	}
+
+	// Ctrl+X b inserts the bold code
+	c.handle(tea.KeyMsg{Type: tea.KeyCtrlX})
+	c.handle(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	c.m.textarea.InsertString("loud")
+	c.handle(tea.KeyMsg{Type: tea.KeyEnter})
+	if params := conn.expect("PRIVMSG"); params[1] != "\x02loud" {
+		t.Errorf("sent %q, want bold text", params[1])
+	}
+}
+
+func TestIgnoredCTCPGetsNoReply(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	c.input("/ignore *!alice@* ctcps")
+	c.waitForView("*!alice@* [ctcps]")
+	conn.from("alice", "PRIVMSG tester :\x01VERSION\x01")
+	conn.from("alice", "PRIVMSG tester :\x01PING 1\x01")
+	conn.from("bob", "PRIVMSG tester :\x01PING 2\x01")
+
+	// Lines are answered in order, so bob's reply coming first means
+	// alice's requests got none
+	if params := conn.expect("NOTICE"); params[0] != "bob" || params[1] != "\x01PING 2\x01" {
+		t.Errorf("first CTCP reply went to %q: %q", params[0], params[1])
+	}
+}
+
+func TestKick(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	conn.from("bob", "KICK #test alice :behave")
+	c.waitForLine("#test", "bob kicked alice from #test (behave)")
+	channel, _ := c.m.findBuffer(c.network().name, "#test")
+	if _, ok := c.m.members(channel)["alice"]; ok {
+		t.Error("alice is still listed after being kicked")
+	}
+
+	conn.from("bob", "KICK #test tester :out")
+	c.waitForLine("#test", "bob kicked you from #test (out)")
+	if members := c.m.members(channel); channel.joined || len(members) != 0 {
+		t.Errorf("still joined after our kick: joined=%v members=%d", channel.joined, len(members))
+	}
+	if got := c.network().session.Channels(); len(got) != 0 {
+		t.Errorf("session still in %v", got)
+	}
+}
+
+// Servers may echo our own nick in a different case; it is still us
+func TestOwnNickCaseChangedEcho(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	conn.from("TESTER", "JOIN #other")
+	c.waitFor("the #other buffer", func() bool {
+		channel, ok := c.m.findBuffer(c.network().name, "#other")
+		return ok && channel.joined
+	})
+
+	conn.from("Tester", "NICK renamed")
+	c.waitFor("the nick change", func() bool { return c.network().nick == "renamed" })
+}
+
+func TestNetsplit(t *testing.T) {
+	c, conn := connectToChannel(t, newFakeServer(t, nil), false)
+
+	conn.from("alice", "QUIT :hub.test leaf.test")
+	conn.from("carol", "QUIT :hub.test leaf.test")
+	c.waitForLine("#test", "Netsplit hub.test <-> leaf.test, 2 quit: alice, carol")
+	if text := c.bufferText("#test"); strings.Contains(text, "alice quit") {
+		t.Errorf("split quits shown one by one:\n%s", text)
+	}
+
+	conn.from("alice", "JOIN #test")
+	c.waitForLine("#test", "Netsplit over hub.test <-> leaf.test, 1 rejoined: alice")
+
+	// The split is over for alice once she is back; joining again later is
+	// an ordinary join
+	conn.from("alice", "PART #test")
+	conn.from("alice", "JOIN #test")
+	c.waitForLine("#test", "alice joined #test")
+	if text := c.bufferText("#test"); strings.Count(text, "rejoined") != 1 {
+		t.Errorf("second join folded into a netsplit summary:\n%s", text)
+	}
+}
+
+func TestDisconnectAndReconnect(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	c, conn := connectToChannel(t, srv, false)
+
+	conn.close()
+	c.waitForView("Disconnected from IRC server")
+	if c.network().connected {
+		t.Error("network still marked connected")
+	}
+	if view := c.view(); !strings.Contains(view, "Reconnecting in") {
+		t.Errorf("view does not show the reconnect:\n%s", view)
+	}
+
+	// The supervisor dials again and rejoins the channel
+	c.waitFor("the client to dial again", func() bool { return len(srv.conns) > 0 })
+	conn = srv.accept()
+	conn.register()
+	c.waitForConnect()
+	conn.acceptJoin("#test", "", "alice")
+	c.waitFor("the rejoin", func() bool {
+		channel, _ := c.m.findBuffer(c.network().name, "#test")
+		return c.network().connected && channel.joined && len(c.m.members(channel)) == 2
+	})
+}
+
+func TestReconnectWhileConnecting(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	c := startClient(t, srv, false, "#test")
+	srv.accept()
+
+	// Registration has not finished, so the first attempt is still pending
+	c.input("/reconnect now")
+	c.waitForView("Already connecting to IRC server")
+	select {
+	case <-srv.conns:
+		t.Error("client dialed a second connection")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestDisconnectWhileConnecting(t *testing.T) {
+	srv := newFakeServer(t, nil)
+	c := startClient(t, srv, false, "#test")
+	conn := srv.accept()
+	conn.register()
+
+	// The session and its Connected are still queued when the user gives up
+	c.input("/disconnect")
+	c.waitForView("Use /reconnect to connect again")
+	if net := c.network(); net.connected || net.connecting || net.reconnect.waiting {
+		t.Errorf("network not left disconnected: connected=%v connecting=%v waiting=%v",
+			net.connected, net.connecting, net.reconnect.waiting)
+	}
+	select {
+	case <-srv.conns:
+		t.Error("client dialed again after /disconnect")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTLS(t *testing.T) {
+	c, conn := connectToChannel(t, newTLSFakeServer(t), true)
+
+	conn.from("alice", "PRIVMSG #test :secret hello")
+	c.waitForLine("#test", "<alice> secret hello")
+}
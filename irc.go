@@ -6,140 +6,173 @@ import (
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
-	irc "github.com/fluffle/goirc/client"
+
+	"goirc/session"
 )
 
-func (m *model) connectToIRC() tea.Cmd {
-	return func() tea.Msg {
-		cfg := irc.NewConfig(m.config.IRC.Nick)
-		cfg.SSL = m.config.IRC.UseSSL
+// ctcpVersion is the reply sent to CTCP VERSION
+func ctcpVersion() string {
+	return "GoIRC " + version
+}
 
-		if m.config.IRC.UseSSL {
-			serverHost := strings.Split(m.config.IRC.Server, ":")[0]
-			cfg.SSLConfig = &tls.Config{ServerName: serverHost}
-		}
+// ircHandlers carries the events of one session to Update. It runs on the
+// session's goroutines, so it never sees the model: every event is sent to
+// Update as a message, and everything here is safe to share.
+type ircHandlers struct {
+	network string
+	events  *eventStream
+	ignores *ignoreList
+}
 
+// newSession makes the session of a network. Tests wrap it to trust the
+// certificate of their own TLS server.
+var newSession = session.New
+
+func (h *ircHandlers) send(msg tea.Msg) {
+	h.events.send(msg)
+}
+
+func (m *model) connectToIRC(net *network) tea.Cmd {
+	network := net.name
+	ircCfg := net.config
+	connect := newSession
+	h := &ircHandlers{network: network, events: m.events, ignores: m.ignores}
+
+	return func() tea.Msg {
 		// Handle port configuration
-		server := m.config.IRC.Server
-		if m.config.IRC.Port != 0 && !strings.Contains(server, ":") {
-			server = fmt.Sprintf("%s:%d", server, m.config.IRC.Port)
+		server := ircCfg.Server
+		if ircCfg.Port != 0 && !strings.Contains(server, ":") {
+			server = fmt.Sprintf("%s:%d", server, ircCfg.Port)
 		}
-		cfg.Server = server
 
-		// Set additional IRC config fields
-		if m.config.IRC.Username != "" {
-			cfg.Me.Ident = m.config.IRC.Username
+		cfg := session.Config{
+			Server:      server,
+			Nick:        ircCfg.Nick,
+			Ident:       ircCfg.Username,
+			RealName:    ircCfg.RealName,
+			Password:    ircCfg.Password,
+			QuitMessage: ircCfg.QuitMsg,
+			TLS:         ircCfg.UseSSL,
+			Version:     ctcpVersion(),
+			Ignore:      h.ignored,
+			// multi-prefix gives every membership prefix in NAMES, not just the highest
+			Capabilities: []string{"multi-prefix"},
 		}
-		if m.config.IRC.RealName != "" {
-			cfg.Me.Name = m.config.IRC.RealName
+		if ircCfg.UseSSL {
+			serverHost := strings.Split(ircCfg.Server, ":")[0]
+			cfg.TLSConfig = &tls.Config{ServerName: serverHost}
 		}
-		if m.config.IRC.Password != "" {
-			cfg.Pass = m.config.IRC.Password
-		}
-		if m.config.IRC.QuitMsg != "" {
-			cfg.QuitMessage = m.config.IRC.QuitMsg
-		}
-
-		cfg.NewNick = func(n string) string { return n + "_" }
-
-		c := irc.Client(cfg)
-
-		c.HandleFunc(irc.CONNECTED, func(conn *irc.Conn, line *irc.Line) {
-			m.logger.LogIRCEvent("Connected to IRC server %s", m.config.IRC.Server)
-			m.logger.Debug("Our actual nickname is: %s", conn.Me().Nick)
 
-			// Join channels after connection
-			for _, channel := range m.config.IRC.Channels {
-				if channel != "" {
-					m.addChannel(channel)
-					conn.Join(channel)
-					m.logger.LogIRCEvent("Joining channel %s", channel)
+		// SASL runs during CAP negotiation, before registration completes
+		saslCfg := ircCfg.SASL
+		if saslCfg.Enabled() {
+			if err := saslCfg.Validate(cfg.TLS); err != nil {
+				return ircErrorMsg{network, err}
+			}
+			if strings.EqualFold(saslCfg.Mechanism, saslExternal) {
+				cert, err := loadClientCertificate(saslCfg)
+				if err != nil {
+					return ircErrorMsg{network, err}
 				}
+				cfg.TLSConfig.Certificates = []tls.Certificate{cert}
 			}
-
-			if p != nil {
-				p.Send(ircConnectedMsg{})
+			client, err := newSASLClient(saslCfg)
+			if err != nil {
+				return ircErrorMsg{network, err}
 			}
-		})
+			cfg.SASL = client
+		}
 
-		c.HandleFunc(irc.DISCONNECTED, func(conn *irc.Conn, line *irc.Line) {
-			m.logger.LogIRCEvent("Disconnected from IRC server")
-			if p != nil {
-				p.Send(ircDisconnectedMsg{})
-			}
-		})
-
-		c.HandleFunc(irc.NICK, func(conn *irc.Conn, line *irc.Line) {
-			oldNick := line.Nick
-			newNick := line.Args[0]
-			m.logger.LogIRCEvent("%s changed nick to %s", oldNick, newNick)
-			if p != nil {
-				p.Send(ircNickChangeMsg{oldNick: oldNick, newNick: newNick})
-			}
-		})
-
-		c.HandleFunc(irc.PRIVMSG, func(conn *irc.Conn, line *irc.Line) {
-			user := line.Nick
-			channel := line.Args[0]
-			message := line.Args[1]
-			m.logger.LogIRCMessage(channel, user, message)
-			if p != nil {
-				p.Send(ircPrivmsgMsg{user: user, message: message, channel: channel})
-			}
-		})
+		sess := connect(cfg)
+		go h.forward(sess)
+		if err := sess.Connect(); err != nil {
+			return ircConnectFailedMsg{network, err}
+		}
 
-		c.HandleFunc(irc.NOTICE, func(conn *irc.Conn, line *irc.Line) {
-			user := line.Nick
-			if user == "" {
-				user = line.Host
-			}
-			message := line.Args[1]
-			m.logger.LogIRCEvent("Notice from %s: %s", user, message)
-			if p != nil {
-				p.Send(ircMessageMsg(formatNoticeMessage(user, message)))
-			}
-		})
+		return ircClientReadyMsg{network: network, session: sess}
+	}
+}
 
-		c.HandleFunc(irc.JOIN, func(conn *irc.Conn, line *irc.Line) {
-			user := line.Nick
-			channel := line.Args[0]
-			m.logger.LogIRCEvent("%s joined %s", user, channel)
+// ignored is the session's Ignore hook: messages, notices and CTCPs from
+// ignored users are dropped before they are answered or shown
+func (h *ircHandlers) ignored(event session.Event) bool {
+	switch e := event.(type) {
+	case session.Privmsg:
+		return h.ignores.ignoredUser(e.From, ignoreMsgs)
+	case session.Action:
+		return h.ignores.ignoredUser(e.From, ignoreMsgs)
+	case session.Notice:
+		return h.ignores.ignoredUser(e.From, ignoreNotices)
+	case session.CTCP:
+		return h.ignores.ignoredUser(e.From, ignoreCTCPs)
+	case session.CTCPReply:
+		return h.ignores.ignoredUser(e.From, ignoreCTCPs)
+	}
+	return false
+}
 
-			if p != nil {
-				p.Send(ircJoinMsg{user: user, channel: channel})
-			}
-		})
-
-		c.HandleFunc(irc.PART, func(conn *irc.Conn, line *irc.Line) {
-			user := line.Nick
-			channel := line.Args[0]
-			message := ""
-			if len(line.Args) > 1 {
-				message = line.Args[1]
-			}
-			m.logger.LogIRCEvent("%s left %s (%s)", user, channel, message)
-			if p != nil {
-				p.Send(ircMessageMsg(formatPartMessage(user, channel, message)))
-			}
-		})
+// forward turns the events of a session into messages for Update until
+// the session ends
+func (h *ircHandlers) forward(sess *session.Session) {
+	for event := range sess.Events() {
+		if msg := h.message(sess, event); msg != nil {
+			h.send(msg)
+		}
+	}
+}
 
-		c.HandleFunc(irc.QUIT, func(conn *irc.Conn, line *irc.Line) {
-			user := line.Nick
-			message := ""
-			if len(line.Args) > 0 {
-				message = line.Args[0]
-			}
-			m.logger.LogIRCEvent("%s quit (%s)", user, message)
-			if p != nil {
-				p.Send(ircMessageMsg(formatQuitMessage(user, message)))
-			}
-		})
+// message is the Update message for a session event. Joins, parts, quits
+// and nick changes of ignored users still come through, marked hidden, as
+// they keep the nick lists right.
+func (h *ircHandlers) message(sess *session.Session, event session.Event) tea.Msg {
+	network := h.network
+	hidden := func(from session.User, level string) bool {
+		return !strings.EqualFold(from.Nick, sess.Me()) && h.ignores.ignoredUser(from, level)
+	}
 
-		if err := c.Connect(); err != nil {
-			m.logger.LogError("Error connecting to IRC: %v", err)
-			return ircErrorMsg{err}
+	switch e := event.(type) {
+	case session.Connected:
+		return ircConnectedMsg{network: network, nick: e.Nick}
+	case session.Disconnected:
+		return ircDisconnectedMsg{network: network}
+	case session.Error:
+		return ircErrorMsg{network, e.Err}
+	case session.Authenticated:
+		return ircAuthenticatedMsg{network: network, message: e.Text}
+	case session.Privmsg:
+		return ircPrivmsgMsg{network: network, user: e.From.Nick, message: e.Text, channel: e.Target}
+	case session.Action:
+		return ircActionMsg{network: network, user: e.From.Nick, channel: e.Target, message: e.Text}
+	case session.Notice:
+		user := e.From.Nick
+		if user == "" {
+			user = e.From.Host
 		}
-
-		return ircClientReadyMsg{client: c}
+		return ircNoticeMsg{network: network, user: user, target: e.Target, message: e.Text}
+	case session.CTCP:
+		return ircCTCPMsg{network: network, user: e.From.Nick, channel: e.Target, command: e.Command, args: e.Args, rateLimited: e.RateLimited}
+	case session.CTCPReply:
+		return ircCTCPReplyMsg{network: network, user: e.From.Nick, command: e.Command, args: e.Args}
+	case session.NickChange:
+		return ircNickChangeMsg{network: network, oldNick: e.From.Nick, newNick: e.New, channels: e.Channels, hidden: hidden(e.From, ignoreNicks)}
+	case session.Join:
+		return ircJoinMsg{network: network, user: e.From.Nick, channel: e.Channel, hidden: hidden(e.From, ignoreJoins)}
+	case session.Part:
+		return ircPartMsg{network: network, user: e.From.Nick, channel: e.Channel, message: e.Reason, hidden: hidden(e.From, ignoreParts)}
+	case session.Quit:
+		return ircQuitMsg{network: network, user: e.From.Nick, message: e.Reason, channels: e.Channels, hidden: hidden(e.From, ignoreQuits)}
+	case session.Kick:
+		return ircKickMsg{network: network, kicker: e.From.Nick, channel: e.Channel, target: e.Target, reason: e.Reason}
+	case session.Mode:
+		return ircModeMsg{network: network, setter: e.Setter, target: e.Target, modes: e.Modes, args: e.Args}
+	case session.Topic:
+		return ircTopicMsg{network: network, channel: e.Channel, topic: e.Topic, setBy: e.SetBy, changed: e.Changed}
+	case session.TopicWhoTime:
+		return ircTopicWhoTimeMsg{network: network, channel: e.Channel, setBy: e.SetBy, setAt: e.SetAt}
+	case session.Members:
+		return ircMembersMsg{network: network, channel: e.Channel, members: e.Members}
+	case session.JoinError:
+		return ircChannelErrorMsg{network: network, channel: e.Channel, message: e.Text}
 	}
+	return nil
 }
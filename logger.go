@@ -128,15 +128,6 @@ func (l *Logger) Debug(format string, v ...interface{}) {
 	}
 }
 
-// LogIRCMessage logs an IRC message with proper formatting
-func (l *Logger) LogIRCMessage(channel, user, message string) {
-	if channel == "" {
-		l.Log("<%s> %s", user, message)
-	} else {
-		l.Log("[%s] <%s> %s", channel, user, message)
-	}
-}
-
 // LogIRCEvent logs an IRC event (joins, parts, etc.)
 func (l *Logger) LogIRCEvent(event string, args ...interface{}) {
 	l.Log("* "+event, args...)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -12,14 +13,37 @@ import (
 var version = "dev"
 
 func main() {
+	opts, err := parseArgs(os.Args[1:], os.Stderr)
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "goirc: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.version {
+		fmt.Println(ctcpVersion())
+		return
+	}
+
 	// Load configuration
-	config, err := LoadConfig()
-	if err != nil {
+	config, err := opts.loadConfig()
+	if err != nil && (opts.configPath != "" || opts.profile != "") {
+		// Never fall back to defaults that would be saved over the named file
+		fmt.Fprintf(os.Stderr, "goirc: failed to load config: %v\n", err)
+		os.Exit(1)
+	} else if err != nil {
 		fmt.Printf("Failed to load config: %v\n", err)
 		fmt.Println("Using default configuration...")
 		config = DefaultConfig()
 	}
 
+	// Apply the command line and validate it before anything needs closing
+	m, err := opts.newModel(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goirc: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize logger
 	logger, err := NewLogger(config)
 	if err != nil {
@@ -46,13 +70,16 @@ func main() {
 		}
 	}
 
-	prog := tea.NewProgram(initialModel(), progOptions...)
-	p = prog
-
-	if _, err := p.Run(); err != nil {
+	final, err := tea.NewProgram(m, progOptions...).Run()
+	m.events.close()
+	if err != nil {
 		logger.LogError("Error running program: %v", err)
+		logger.Close()
 		log.Fatal("Error running program:", err)
 	}
+	if m, ok := final.(model); ok && m.chatLog != nil {
+		m.chatLog.Close()
+	}
 
 	logger.LogShutdown()
 	logger.Log("GoIRC Client stopped.")
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"goirc/session"
+)
+
+const nicklistWidth = 22
+
+// formatModeChanges rebuilds a MODE string such as "+o-v alice bob"
+func formatModeChanges(changes []session.ModeChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var modes strings.Builder
+	var args []string
+	var sign byte
+	for _, change := range changes {
+		next := byte('-')
+		if change.Adding {
+			next = '+'
+		}
+		if next != sign {
+			modes.WriteByte(next)
+			sign = next
+		}
+		modes.WriteByte(change.Mode)
+		if change.Arg != "" {
+			args = append(args, change.Arg)
+		}
+	}
+	return strings.Join(append([]string{modes.String()}, args...), " ")
+}
+
+// members maps the nicks in a channel to their prefix symbols, as of the
+// session's last event about them
+func (m *model) members(channel *channelData) map[string]string {
+	return channel.members
+}
+
+// topic is a channel's topic, as of the session's last event about it
+func (m *model) topic(channel *channelData) string {
+	return channel.topic
+}
+
+// renameSpoke carries a nick's last message time over to its new nick
+func (c *channelData) renameSpoke(oldNick, newNick string) {
+	if spoke, ok := c.spoke[strings.ToLower(oldNick)]; ok {
+		delete(c.spoke, strings.ToLower(oldNick))
+		c.spoke[strings.ToLower(newNick)] = spoke
+	}
+}
+
+// channelBuffers lists the keys of the open buffers for channels on a network
+func (m *model) channelBuffers(network string, channels []string) []string {
+	var keys []string
+	for _, name := range channels {
+		if channel, ok := m.findBuffer(network, name); ok && !channel.isQuery {
+			keys = append(keys, channel.key())
+		}
+	}
+	return keys
+}
+
+// sortedMembers returns prefixed nicks sorted by rank, then by nick
+func (m *model) sortedMembers(channel *channelData) []string {
+	modes := session.DefaultModes()
+	if net := m.network(channel.network); net != nil {
+		modes = net.modes()
+	}
+
+	type member struct {
+		nick, prefix string
+		rank         int
+	}
+	current := m.members(channel)
+	members := make([]member, 0, len(current))
+	for nick, prefix := range current {
+		members = append(members, member{nick: nick, prefix: prefix, rank: modes.Rank(prefix)})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].rank != members[j].rank {
+			return members[i].rank < members[j].rank
+		}
+		return strings.ToLower(members[i].nick) < strings.ToLower(members[j].nick)
+	})
+
+	result := make([]string, len(members))
+	for i, mem := range members {
+		symbol := " "
+		if mem.prefix != "" {
+			symbol = mem.prefix[:1]
+		}
+		result[i] = symbol + mem.nick
+	}
+	return result
+}
+
+// nicklistVisible reports whether the member pane should be drawn: it
+// makes way when the chat beside it would get too narrow
+func (m *model) nicklistVisible() bool {
+	channel, exists := m.channels[m.currentBuffer]
+	chat := chatAreaStyle.GetWidth() - chatAreaStyle.GetHorizontalPadding() - nicklistWidth
+	return m.showNicklist && exists && !channel.isQuery && chat >= minChatWidth
+}
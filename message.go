@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// MessageKind says what a message is, and so how it is drawn
+type MessageKind string
+
+const (
+	KindSystem    MessageKind = "system" // Client status and command output
+	KindError     MessageKind = "error"
+	KindSwitch    MessageKind = "switch"    // "Now viewing" marker after a buffer switch
+	KindSeparator MessageKind = "separator" // Divides replayed history from live traffic
+	KindChat      MessageKind = "chat"      // PRIVMSG from someone else
+	KindOwn       MessageKind = "own"       // PRIVMSG we sent
+	KindAction    MessageKind = "action"    // CTCP ACTION (/me)
+	KindNotice    MessageKind = "notice"
+	KindCTCP      MessageKind = "ctcp"
+	KindJoin      MessageKind = "join"
+	KindPart      MessageKind = "part"
+	KindQuit      MessageKind = "quit"
+	KindKick      MessageKind = "kick"
+	KindBan       MessageKind = "ban"
+	KindMode      MessageKind = "mode"
+	KindTopic     MessageKind = "topic"
+)
+
+// Message is one line of a buffer's history. It is kept unstyled and only
+// rendered when drawn, so the history can be reflowed, re-themed, searched
+// and logged.
+type Message struct {
+	Time   time.Time   `json:"time"`
+	Kind   MessageKind `json:"kind"`
+	Sender string      `json:"sender,omitempty"`
+	Target string      `json:"target,omitempty"` // Channel or query the message belongs to
+	// Text is what was said for chat, actions and notices, and the plain
+	// description of the event for everything else
+	Text      string            `json:"text"`
+	Tags      map[string]string `json:"tags,omitempty"` // IRCv3 message tags
+	Highlight bool              `json:"highlight,omitempty"`
+}
+
+// now is the clock behind message timestamps, uptimes and reconnect
+// countdowns. Tests freeze it so rendered screens come out the same every
+// run.
+var now = time.Now
+
+// newMessage stamps a message with the current time
+func newMessage(kind MessageKind, sender, target, text string) Message {
+	return Message{Time: now(), Kind: kind, Sender: sender, Target: target, Text: text}
+}
+
+// body is the message as shown after the timestamp, without styling
+func (msg Message) body() string {
+	switch msg.Kind {
+	case KindChat, KindOwn:
+		return fmt.Sprintf("<%s> %s", msg.Sender, msg.Text)
+	case KindAction:
+		return fmt.Sprintf("* %s %s", msg.Sender, msg.Text)
+	case KindNotice:
+		return fmt.Sprintf("[%s] %s", msg.Sender, msg.Text)
+	case KindError:
+		return "! " + msg.Text
+	}
+	return msg.Text
+}
+
+// style picks the style for a kind of message
+func (msg Message) style() lipgloss.Style {
+	if msg.Highlight {
+		return highlightMessageStyle
+	}
+	switch msg.Kind {
+	case KindError:
+		return errorMessageStyle
+	case KindSwitch, KindSeparator:
+		return channelSwitchStyle
+	case KindChat:
+		return userMessageStyle
+	case KindOwn:
+		return ownMessageStyle
+	case KindAction:
+		return actionMessageStyle
+	case KindNotice:
+		return noticeMessageStyle
+	case KindCTCP:
+		return ctcpMessageStyle
+	case KindJoin:
+		return joinMessageStyle
+	case KindPart:
+		return partMessageStyle
+	case KindQuit:
+		return quitMessageStyle
+	case KindKick, KindBan:
+		return kickMessageStyle
+	case KindMode:
+		return modeMessageStyle
+	case KindTopic:
+		return topicMessageStyle
+	}
+	return systemMessageStyle
+}
+
+// render draws a message wrapped to width, indenting continuation lines
+// under the text so the timestamp column stays clear. mIRC formatting in
+// the text is drawn, or dropped when strip is set.
+func (msg Message) render(width int, strip bool) string {
+	timestamp := msg.Time.Format("15:04")
+	indent := len(timestamp) + 1
+
+	lines := wrapSpans(msg.spans(strip), width-indent, msg.style())
+	for i, line := range lines {
+		prefix := strings.Repeat(" ", indent)
+		if i == 0 {
+			prefix = timestampStyle.Render(timestamp) + " "
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// spans splits the body into runs to draw: the text with its mIRC
+// formatting, unless stripped, and the sender of chat, in its nick color
+// unless it is us
+func (msg Message) spans(strip bool) []span {
+	var before, after string
+	switch msg.Kind {
+	case KindChat, KindOwn:
+		before, after = "<", "> "
+	case KindAction:
+		before, after = "* ", " "
+	}
+
+	body := msg.Text
+	if before == "" {
+		body = msg.body()
+	}
+	spans := parseFormatting(body)
+	if strip {
+		for i := range spans {
+			spans[i].format = textFormat{}
+		}
+	}
+	if before == "" {
+		return spans
+	}
+
+	// Highlights keep their own colors so they stand out, and our own
+	// nick is drawn in the style of our messages
+	sender := textFormat{}
+	if !msg.Highlight && msg.Kind != KindOwn {
+		sender.fg = nickColor(msg.Sender)
+	}
+	return append([]span{{text: before}, {text: msg.Sender, format: sender}, {text: after}}, spans...)
+}
+
+// chatWidth is the number of columns messages are wrapped to
+func (m *model) chatWidth() int {
+	width := chatAreaStyle.GetWidth() - chatAreaStyle.GetHorizontalPadding()
+	if m.nicklistVisible() {
+		width -= nicklistWidth
+	}
+	return width
+}
+
+// reflow rewraps the history, e.g. after a resize or theme change. Lines
+// are rendered again as they come into view.
+func (m *model) reflow() {
+	m.viewport.Width = m.chatWidth()
+	m.viewport.invalidate()
+	m.viewport.clamp()
+	m.syncScroll()
+}
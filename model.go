@@ -4,13 +4,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/textarea"
-	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"goirc/session"
 )
 
 func initialModel() model {
@@ -20,7 +22,11 @@ func initialModel() model {
 		// Fallback to default config if loading fails
 		config = DefaultConfig()
 	}
+	return newModel(config)
+}
 
+// newModel builds the model for a loaded config, starting at the setup wizard
+func newModel(config *Config) model {
 	// Initialize logger
 	logger, err := NewLogger(config)
 	if err != nil {
@@ -28,6 +34,14 @@ func initialModel() model {
 		logger = &Logger{config: config}
 	}
 
+	// Styles are built before the UI starts, which is also when the
+	// terminal can still be asked for its background color
+	if theme, err := loadTheme(string(config.UI.Theme)); err != nil {
+		logger.LogError("Failed to load theme, using the default: %v", err)
+	} else {
+		applyTheme(theme)
+	}
+
 	ta := textarea.New()
 	ta.Focus()
 	ta.Prompt = "▶ "
@@ -36,15 +50,20 @@ func initialModel() model {
 	ta.SetHeight(1)
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
 	ta.ShowLineNumbers = false
+	// Enter sends; the input is always a single line
+	ta.KeyMap.InsertNewline.SetEnabled(false)
 
-	vp := viewport.New(minWidth, 10)
+	messages := newMessageRing(config.UI.ScrollbackLines)
+	view := newChatView(messages, minWidth, 10)
+	view.Strip = config.UI.StripFormatting
+	// Bad patterns are reported when the config is validated on connect
+	highlights, _ := newHighlighter(config.Highlight)
 
 	return model{
 		textarea:         ta,
-		messages:         []string{},
-		viewport:         vp,
+		messages:         messages,
+		viewport:         view,
 		ready:            false,
-		connected:        false,
 		width:            minWidth,
 		height:           minHeight,
 		state:            stateSetup,
@@ -57,7 +76,14 @@ func initialModel() model {
 		activeChannels:   []string{},
 		showSidebar:      config.UI.ShowSidebar,
 		sidebarWidth:     config.UI.SidebarWidth,
+		showNicklist:     config.UI.ShowNicklist,
+		networks:         make(map[string]*network),
+		history:          loadHistory(),
 		logger:           logger,
+		chatLog:          newChatLogger(config),
+		highlighter:      highlights,
+		ignores:          newIgnoreList(config.Ignores),
+		events:           newEventStream(),
 		// Initialize command palette
 		commandPaletteVisible:  false,
 		commandPaletteQuery:    "",
@@ -68,7 +94,25 @@ func initialModel() model {
 }
 
 func (m model) Init() tea.Cmd {
-	return textarea.Blink
+	cmds := []tea.Cmd{textarea.Blink, m.events.wait()}
+	// Started from the command line rather than the wizard
+	if m.state == stateConnecting {
+		cmds = append(cmds, m.connectAll())
+	}
+	return tea.Batch(cmds...)
+}
+
+// finishSetup leaves the setup wizard for the configured networks and
+// saves the config. The caller connects them, or Init does when the wizard
+// was skipped from the command line.
+func (m *model) finishSetup() error {
+	if err := m.initNetworks(); err != nil {
+		return err
+	}
+	m.state = stateConnecting
+	m.updateDimensions()
+	m.saveConfig()
+	return nil
 }
 
 func (m *model) handleSetupInput(input string) tea.Cmd {
@@ -162,12 +206,13 @@ func (m *model) handleSetupInput(input string) tea.Cmd {
 			m.setupValidationError = ""
 			m.textarea.SetValue("")
 		} else if strings.ToLower(input) == "y" || strings.ToLower(input) == "yes" || input == "" {
-			m.state = stateConnecting
+			if err := m.finishSetup(); err != nil {
+				m.setupValidationError = err.Error()
+				return nil
+			}
 			m.setupValidationError = ""
 			m.textarea.SetValue("")
-			// Save configuration after setup is complete
-			m.saveConfig()
-			return m.connectToIRC()
+			return m.connectAll()
 		} else if strings.ToLower(input) == "n" || strings.ToLower(input) == "no" {
 			m.setupPhase = setupServer
 			m.setupValidationError = ""
@@ -183,34 +228,31 @@ func (m *model) handleSetupInput(input string) tea.Cmd {
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		tiCmd tea.Cmd
-		vpCmd tea.Cmd
+		cmd   tea.Cmd
 	)
 
+	// A burst of IRC events is applied in one go so it costs a single render
+	if batch, ok := msg.(ircBatchMsg); ok {
+		var next tea.Model = m
+		cmds := make([]tea.Cmd, 0, len(batch)+1)
+		for _, event := range batch {
+			m.logIRCEvent(event)
+			var eventCmd tea.Cmd
+			next, eventCmd = next.Update(event)
+			cmds = append(cmds, eventCmd)
+		}
+		// Listen for the next batch
+		cmds = append(cmds, m.events.wait())
+		return next, tea.Batch(cmds...)
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		m.ready = true
 
-		UpdateStyleWidths(m.width)
-
-		if !m.ready {
-			m.viewport = viewport.New(msg.Width, msg.Height-headerHeight-footerHeight-statusHeight)
-			m.viewport.YPosition = headerHeight + statusHeight
-			m.ready = true
-		} else {
-			viewportWidth := msg.Width
-			if m.showSidebar {
-				viewportWidth -= m.sidebarWidth
-			}
-			m.viewport.Width = viewportWidth
-			m.viewport.Height = msg.Height - headerHeight - footerHeight - statusHeight
-		}
-
-		textareaWidth := msg.Width
-		if m.showSidebar {
-			textareaWidth -= m.sidebarWidth
-		}
-		m.textarea.SetWidth(textareaWidth - 4)
+		m.updateDimensions()
 	}
 
 	if m.state == stateSetup {
@@ -241,63 +283,313 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case ircClientReadyMsg:
-		m.ircClient = msg.client
-		m.currentNick = m.config.IRC.Nick
+		if net := m.network(msg.network); net != nil {
+			net.session = msg.session
+			net.nick = net.config.Nick
+			// /disconnect came while this session was being dialed
+			if net.reconnect.stopped {
+				net.session.Close()
+			}
+		}
 
 	case ircConnectedMsg:
-		m.connected = true
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		// Registration finished after a /disconnect; the closed session's
+		// Disconnected follows
+		if net.reconnect.stopped {
+			break
+		}
+		reconnecting := net.reconnect.active
+		net.connected = true
+		net.connecting = false
+		net.connectionTime = now()
+		net.reconnect = reconnectState{}
+		if msg.nick != "" {
+			net.nick = msg.nick
+		}
 		m.state = stateConnected
-		m.addMessage(formatSystemMessage("Connected to IRC server"))
+		m.addMessage(formatSystemMessage(m.networkLabel(net.name) + "Connected to IRC server"))
+		m.joinChannels(net, reconnecting)
 
-		for _, channel := range m.config.IRC.Channels {
-			m.autoJoinChannels = append(m.autoJoinChannels, channel)
-			m.addChannel(channel)
-			if m.ircClient != nil {
-				m.ircClient.Join(channel)
-			}
+	case ircDisconnectedMsg:
+		if net := m.network(msg.network); net != nil {
+			return m, m.handleDisconnect(net)
 		}
 
-	case ircDisconnectedMsg:
-		m.connected = false
-		m.state = stateSetup
-		m.addMessage(formatErrorMessage("Disconnected from IRC server"))
+	case ircConnectFailedMsg:
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		net.connecting = false
+		m.logger.LogError("Error connecting to %s: %v", net.name, msg.err)
+		// With other networks still up, a failed dial is retried rather than
+		// fatal, unless the user has cancelled it
+		if (net.reconnect.active || len(m.networkOrder) > 1) && !net.reconnect.stopped {
+			m.addMessage(formatErrorMessage(fmt.Sprintf("%sConnection failed: %v", m.networkLabel(net.name), msg.err)))
+			return m, m.scheduleReconnect(net)
+		}
+		m.err = msg.err
+		m.addMessage(formatErrorMessage(msg.err.Error()))
 
-	case ircMessageMsg:
-		m.addMessage(string(msg))
+	case reconnectTickMsg:
+		return m, m.handleReconnectTick(msg)
 
-	case ircPrivmsgMsg:
-		message := formatUserMessageWithContext(msg.user, msg.message, m.currentNick)
-		m.addMessageToChannel(msg.channel, message)
+	case alertDoneMsg:
+		if msg.gen == m.alertGen {
+			m.pendingAlert = ""
+		}
 
-		if msg.channel == m.currentChannel {
+	case ircAuthenticatedMsg:
+		m.addMessage(formatSystemMessage(m.networkLabel(msg.network) + msg.message))
+
+	case ircNoticeMsg:
+		from := msg.user
+		if m.networkLabel(msg.network) != "" {
+			from = msg.network + "/" + msg.user
+		}
+		message := formatNoticeMessage(msg.user, msg.message)
+		message.Highlight = m.isHighlight(m.network(msg.network), msg.user, msg.message)
+		if channel, exists := m.findBuffer(msg.network, msg.target); exists && isChannelName(msg.target) {
+			m.addBufferMessage(channel.key(), message)
+			cmd = m.alert(msg.network, channel.key(), message)
+		} else {
+			message.Sender = from
 			m.addMessage(message)
+			cmd = m.alert(msg.network, "", message)
+		}
+
+	case ircPrivmsgMsg:
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		message := formatUserMessageWithContext(msg.user, msg.message, net.nick)
+		message.Highlight = m.isHighlight(net, msg.user, msg.message)
+		if isChannelName(msg.channel) {
+			if channel, exists := m.findBuffer(net.name, msg.channel); exists {
+				channel.noteSpoke(msg.user)
+				m.addBufferMessage(channel.key(), message)
+				cmd = m.alert(net.name, channel.key(), message)
+			}
+		} else {
+			// Private message to us: file it under the sender's query buffer
+			key := m.openQuery(net.name, msg.user).key()
+			m.addBufferMessage(key, message)
+			cmd = m.alert(net.name, key, message)
 		}
 
+	case ircActionMsg:
+		message := formatActionMessage(msg.user, msg.message)
+		message.Highlight = m.isHighlight(m.network(msg.network), msg.user, msg.message)
+		if isChannelName(msg.channel) {
+			if channel, exists := m.findBuffer(msg.network, msg.channel); exists {
+				channel.noteSpoke(msg.user)
+				m.addBufferMessage(channel.key(), message)
+				cmd = m.alert(msg.network, channel.key(), message)
+			}
+		} else {
+			key := m.openQuery(msg.network, msg.user).key()
+			m.addBufferMessage(key, message)
+			cmd = m.alert(msg.network, key, message)
+		}
+
+	case ircCTCPMsg:
+		m.addMessage(formatCTCPRequestMessage(msg.user, msg.command))
+
+	case ircCTCPReplyMsg:
+		reply := msg.args
+		if msg.command == "PING" {
+			// Our own pings carry the send time in nanoseconds
+			if sent, err := strconv.ParseInt(msg.args, 10, 64); err == nil {
+				reply = time.Since(time.Unix(0, sent)).Round(time.Millisecond).String()
+			}
+		}
+		m.addMessage(formatCTCPReplyMessage(msg.user, msg.command, reply))
+
 	case ircErrorMsg:
-		m.err = msg.err
-		m.addMessage(formatErrorMessage(msg.err.Error()))
+		m.err = fmt.Errorf("%s%w", m.networkLabel(msg.network), msg.err)
+		m.addMessage(formatErrorMessage(m.err.Error()))
 
 	case ircNickChangeMsg:
-		if msg.oldNick == m.currentNick {
-			m.currentNick = msg.newNick
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		if strings.EqualFold(msg.oldNick, net.nick) {
+			net.nick = msg.newNick
 		}
 		message := formatSystemMessage(msg.oldNick + " is now known as " + msg.newNick)
-		m.addMessage(message)
+		shown := false
+		for _, key := range m.channelBuffers(net.name, msg.channels) {
+			m.channels[key].renameSpoke(msg.oldNick, msg.newNick)
+			if !msg.hidden {
+				m.addBufferMessage(key, message)
+				shown = shown || key == m.currentBuffer
+			}
+		}
+		if query, ok := m.renameQuery(net.name, msg.oldNick, msg.newNick); ok && !msg.hidden {
+			m.addBufferMessage(query.key(), message)
+			shown = shown || query.key() == m.currentBuffer
+		}
+		if !shown && strings.EqualFold(msg.newNick, net.nick) {
+			m.addMessage(message)
+		}
 
 	case ircJoinMsg:
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
 		message := formatJoinMessage(msg.user, msg.channel)
 
-		if msg.user == m.currentNick {
-			m.setChannelJoined(msg.channel, true)
-			m.switchToChannel(msg.channel)
+		if strings.EqualFold(msg.user, net.nick) {
+			// Rejoins after a reconnect keep the current buffer in view
+			channel := m.addChannel(net.name, msg.channel)
+			wasJoined := channel.joined
+			channel.joined = true
+			// Automatic rejoins leave the view where the user put it
+			if (!wasJoined && !channel.rejoining) || m.currentBuffer == "" {
+				m.switchToChannel(channel.key())
+			}
+			channel.rejoining = false
+		}
+		if channel, exists := m.findBuffer(net.name, msg.channel); exists {
+			if servers, ok := net.splits.returning(msg.user, channel.key()); ok && !msg.hidden {
+				// Shown with the rest of the netsplit's rejoins
+				cmd = net.splits.add(net.name, servers, true, channel.key(), msg.user)
+			} else if !msg.hidden && !m.smartFiltered(channel, msg.user) {
+				m.addBufferMessage(channel.key(), message)
+			}
+		}
+
+	case ircPartMsg:
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		if channel, exists := m.findBuffer(net.name, msg.channel); exists {
+			if !msg.hidden && !m.smartFiltered(channel, msg.user) {
+				m.addBufferMessage(channel.key(), formatPartMessage(msg.user, msg.channel, msg.message))
+			}
+		}
+
+	case ircQuitMsg:
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		if servers, ok := netsplitServers(msg.message); ok {
+			cmd = m.handleNetsplitQuit(net, msg, servers)
+			break
+		}
+		message := formatQuitMessage(msg.user, msg.message)
+		for _, key := range m.channelBuffers(msg.network, msg.channels) {
+			channel := m.channels[key]
+			if !msg.hidden && !m.smartFiltered(channel, msg.user) {
+				m.addBufferMessage(key, message)
+			}
+		}
+		if query, ok := m.findBuffer(msg.network, msg.user); ok && query.isQuery && !msg.hidden {
+			m.addBufferMessage(query.key(), message)
+		}
+
+	case netsplitMsg:
+		m.showNetsplit(msg)
+
+	case ircKickMsg:
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		channel, exists := m.findBuffer(net.name, msg.channel)
+		if !exists {
+			break
+		}
+		if !strings.EqualFold(msg.target, net.nick) {
+			m.addBufferMessage(channel.key(), formatKickMessage(msg.kicker, msg.channel, msg.target, msg.reason))
+			break
+		}
+		channel.joined = false
+		m.addBufferMessage(channel.key(), formatKickMessage(msg.kicker, msg.channel, "you", msg.reason))
+		if net.config.Settings(msg.channel).AutoRejoin {
+			m.addBufferMessage(channel.key(), formatSystemMessage(fmt.Sprintf("Rejoining %s in %v...", msg.channel, autoRejoinDelay)))
+			return m, autoRejoin(net.name, msg.channel)
 		}
 
-		m.addMessageToChannel(msg.channel, message)
+	case autoRejoinMsg:
+		net := m.network(msg.network)
+		if net == nil || !net.connected {
+			break
+		}
+		// Skip if the buffer was closed or rejoined by hand in the meantime
+		if channel, exists := m.findBuffer(net.name, msg.channel); exists && !channel.joined {
+			channel.rejoining = true
+			net.session.Join(channel.name)
+		}
+
+	case ircModeMsg:
+		net := m.network(msg.network)
+		if net == nil {
+			break
+		}
+		if !isChannelName(msg.target) {
+			m.addMessage(formatUserModeMessage(msg.setter, msg.target, msg.modes))
+			break
+		}
+		changes := net.modes().ParseChanges(msg.modes, msg.args)
+		channel, exists := m.findBuffer(net.name, msg.target)
+		if !exists {
+			break
+		}
+		// Bans get a line of their own; everything else is shown as one change
+		var other []session.ModeChange
+		for _, change := range changes {
+			if change.Mode == 'b' && change.Arg != "" {
+				m.addBufferMessage(channel.key(), formatBanMessage(msg.setter, msg.target, change.Arg, change.Adding))
+			} else {
+				other = append(other, change)
+			}
+		}
+		if modes := formatModeChanges(other); modes != "" {
+			m.addBufferMessage(channel.key(), formatModeMessage(msg.setter, msg.target, modes))
+		}
 
-		if msg.channel == m.currentChannel {
+	case ircChannelErrorMsg:
+		message := formatErrorMessage(fmt.Sprintf("Cannot join %s: %s", msg.channel, msg.message))
+		if channel, exists := m.findBuffer(msg.network, msg.channel); exists {
+			channel.rejoining = false
+			m.addBufferMessage(channel.key(), message)
+		} else {
 			m.addMessage(message)
 		}
 
+	case ircTopicMsg:
+		if channel, exists := m.findBuffer(msg.network, msg.channel); exists {
+			channel.topic, channel.topicSetBy, channel.topicSetAt = msg.topic, "", time.Time{}
+			if msg.changed {
+				channel.topicSetBy, channel.topicSetAt = msg.setBy, now()
+				m.addBufferMessage(channel.key(), formatTopicChangeMessage(msg.setBy, msg.channel, msg.topic))
+			} else {
+				m.addBufferMessage(channel.key(), formatTopicMessage(msg.channel, msg.topic))
+			}
+		}
+
+	case ircTopicWhoTimeMsg:
+		if channel, exists := m.findBuffer(msg.network, msg.channel); exists {
+			channel.topicSetBy, channel.topicSetAt = msg.setBy, msg.setAt
+			m.addBufferMessage(channel.key(), formatTopicSetByMessage(msg.setBy, msg.setAt))
+		}
+
+	case ircMembersMsg:
+		if channel, exists := m.findBuffer(msg.network, msg.channel); exists && !channel.isQuery {
+			channel.members = msg.members
+			channel.nicklist = m.sortedMembers(channel)
+		}
+
 	case tea.KeyMsg:
 		// Handle command palette first if it's visible
 		if m.commandPaletteVisible {
@@ -356,6 +648,59 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		// Any other key ends a Tab completion cycle
+		if msg.Type != tea.KeyTab && msg.Type != tea.KeyShiftTab {
+			m.completion = nil
+		}
+
+		if m.search != nil && m.handleHistorySearchKey(msg) {
+			return m, nil
+		}
+
+		if m.formatChord {
+			m.handleFormatKey(msg)
+			return m, nil
+		}
+		if msg.Type == tea.KeyCtrlX {
+			m.formatChord = true
+			return m, nil
+		}
+
+		if m.handleScrollKey(msg) {
+			return m, nil
+		}
+
+		// History keys are taken before the input box and chat view see them
+		switch msg.Type {
+		case tea.KeyUp, tea.KeyDown:
+			m.browseHistory(false, msg.Type == tea.KeyUp)
+			return m, nil
+		case tea.KeyCtrlUp, tea.KeyCtrlDown:
+			m.browseHistory(true, msg.Type == tea.KeyCtrlUp)
+			return m, nil
+		case tea.KeyCtrlR:
+			m.browse = nil
+			m.startHistorySearch()
+			return m, nil
+		default:
+			m.browse = nil
+		}
+
+		switch msg.String() {
+		case "alt+right":
+			m.nextChannel()
+			return m, nil
+		case "alt+left":
+			m.prevChannel()
+			return m, nil
+		case "alt+a":
+			// Jump to the buffer with the most important activity
+			if key, ok := m.nextActive(); ok {
+				m.switchToChannel(key)
+			}
+			return m, nil
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			return m, tea.Quit
@@ -369,15 +714,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 
 		case tea.KeyTab:
-			m.nextChannel()
+			m.complete(true)
 
 		case tea.KeyShiftTab:
-			m.prevChannel()
+			m.complete(false)
 
 		case tea.KeyCtrlB:
 			m.showSidebar = !m.showSidebar
 			m.updateDimensions()
 
+		case tea.KeyCtrlL:
+			m.showNicklist = !m.showNicklist
+			m.updateDimensions()
+
 		case tea.KeyCtrlN:
 			m.nextChannel()
 
@@ -387,17 +736,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if input == "" {
 					break
 				}
+				m.recordHistory(input)
 
 				if strings.HasPrefix(input, "/") {
-					m.handleCommand(input)
+					cmd = m.handleCommand(input)
 				} else {
-					if m.currentChannel != "" && m.ircClient != nil {
-						m.ircClient.Privmsg(m.currentChannel, input)
-						message := formatUserMessage(m.currentNick, input)
-						m.addMessageToChannel(m.currentChannel, message)
+					channel, exists := m.channels[m.currentBuffer]
+					if net := m.currentNetwork(); exists && net.connected {
+						m.scrollToBottom()
+						input = expandFormatting(input)
+						net.session.Privmsg(channel.name, input)
+						echo := input
+						if isSensitive(channel.name, input) {
+							echo = maskCredentials(input)
+						}
+						message := formatOwnMessage(net.nick, echo)
+						m.addMessageToChannel(m.currentBuffer, message)
 						m.addMessage(message)
-						// Log the sent message
-						m.logger.LogIRCMessage(m.currentChannel, m.currentNick, input)
 					}
 				}
 
@@ -410,31 +765,59 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	m.textarea, tiCmd = m.textarea.Update(msg)
-	m.viewport, vpCmd = m.viewport.Update(msg)
 
-	return m, tea.Batch(tiCmd, vpCmd)
+	return m, tea.Batch(tiCmd, cmd)
 }
 
-func (m *model) addMessage(msg string) {
-	m.messages = append(m.messages, msg)
-	m.viewport.SetContent(strings.Join(m.messages, "\n"))
-	m.viewport.GotoBottom()
+func (m *model) addMessage(msg Message) {
+	m.messages.push(msg)
+	// The view only follows new messages when already at the bottom, so
+	// reading scrollback is not interrupted
+	m.viewport.appended()
+	if !m.viewport.AtBottom() {
+		m.newBelow++
+	}
 }
 
+// updateDimensions lays the screen out again for the terminal size and
+// the panes shown
 func (m *model) updateDimensions() {
-	viewportWidth := m.width
-	if m.showSidebar {
-		viewportWidth -= m.sidebarWidth
+	UpdateStyleWidths(m.width, m.sidebarShownWidth())
+	input := inputBoxFocusedStyle
+	if m.state == stateSetup {
+		input = setupInputBoxStyle
+	}
+	m.textarea.SetWidth(input.GetWidth() - input.GetHorizontalPadding())
+
+	// The chat box gets the rows left under the header, topic and status
+	// lines and over the input box and help line
+	m.viewport.Height = max(m.height-headerHeight-topicHeight-statusHeight-footerHeight-chatAreaStyle.GetVerticalFrameSize(), 1)
+
+	// Rewrap the history to the new width
+	m.reflow()
+}
+
+// sidebarShownWidth is the width of the sidebar as drawn: 0 when it is
+// hidden, or when the terminal is too narrow to leave room for the chat
+func (m *model) sidebarShownWidth() int {
+	if !m.showSidebar {
+		return 0
+	}
+	width := m.sidebarWidth
+	if m.width < 120 {
+		width = min(width, 25) // Smaller sidebar for narrow screens
 	}
-	m.viewport.Width = viewportWidth
-	textareaWidth := viewportWidth - 4
-	m.textarea.SetWidth(textareaWidth)
+	chat := m.width - width - sidebarStyle.GetHorizontalBorderSize() - chatAreaStyle.GetHorizontalFrameSize()
+	if width <= sidebarStyle.GetHorizontalPadding() || chat < minChatWidth {
+		return 0
+	}
+	return width
 }
 
-func (m *model) handleCommand(input string) {
+func (m *model) handleCommand(input string) tea.Cmd {
 	parts := strings.Fields(input)
 	if len(parts) == 0 {
-		return
+		return nil
 	}
 
 	command := strings.ToLower(parts[0])
@@ -444,18 +827,44 @@ func (m *model) handleCommand(input string) {
 			"Available commands:",
 			"/join <#channel> - Join a channel",
 			"/part [#channel] - Leave current channel or specified channel",
-			"/switch <#channel> - Switch to a channel (or /sw)",
+			"/switch <#channel> - Switch to a channel (or /sw, network/#channel)",
 			"/nick <nickname> - Change nickname",
+			"/names [#channel] - List users in a channel",
+			"/topic [new topic] - Show or change the channel topic",
+			"/nicklist - Toggle the user list pane",
 			"/msg <user> <message> - Send private message",
+			"/query <nick> [message] - Open a private conversation",
+			"/me <action> - Send an action to the current buffer",
+			"/ctcp <nick> <command> - Send a CTCP query (VERSION, PING, TIME...)",
+			"/close [buffer] - Close a query or leave and close a channel",
 			"/config [show|save|reload] - Manage configuration",
-			"/logging [on|off|debug on|off|status] - Control logging",
-			"/quit [reason] - Quit IRC",
+			"/logging [on|off|chat on|off|debug on|off|status] - Control logging and chat logs",
+			"/filter [on|off] - Only show joins, parts and quits from nicks that spoke recently here",
+			"/ignore [-time 2h] <nick|mask> [levels] - Hide msgs, notices, ctcps, joins, parts, quits or nicks",
+			"/unignore <mask|number> - Remove an ignore",
+			"/ignores - List ignored masks",
+			"/theme [name|list] - Switch the color theme (auto picks dark or light)",
+			"/formatting [show|strip] - Draw or strip colors and bold/italic/underline",
+			"/networks - List networks and their connection status",
+			"/disconnect [reason] - Disconnect this network without reconnecting",
+			"/reconnect [now] - Reconnect this network to its server",
+			"/quit [reason] - Quit all networks",
 			"/help - Show this help",
 			"",
 			"Key bindings:",
-			"Tab - Switch to next channel",
-			"Shift+Tab - Switch to previous channel",
+			"Tab / Shift+Tab - Complete nicks, channels and commands",
+			"Up / Down - Recall input sent in this buffer",
+			"Ctrl+Up / Ctrl+Down - Recall input sent anywhere",
+			"Ctrl+R - Search input history",
+			"Ctrl+X then b/i/u/s/r/c/o - Insert bold, italic, underline, strikethrough, reverse, color or reset",
+			"  (shown as ␂ ␝ ␟ ␞ ␖ ␃ ␏ in the input; type the color numbers after ␃, e.g. ␃4,1)",
+			"PgUp / PgDn - Scroll the chat",
+			"Home / End - Jump to the top or bottom of the chat (Ctrl+Home / Ctrl+End while typing)",
+			"Alt+Right / Ctrl+N - Switch to next channel",
+			"Alt+Left - Switch to previous channel",
+			"Alt+A - Switch to the next buffer with activity (see Act: in the header)",
 			"Ctrl+B - Toggle sidebar",
+			"Ctrl+L - Toggle user list",
 			"Ctrl+C - Exit application",
 		}
 		for _, line := range helpText {
@@ -468,70 +877,232 @@ func (m *model) handleCommand(input string) {
 			if !strings.HasPrefix(channel, "#") {
 				channel = "#" + channel
 			}
-			m.addChannel(channel)
-			if m.ircClient != nil {
-				m.ircClient.Join(channel)
+			if net := m.currentNetwork(); net != nil {
+				m.addChannel(net.name, channel)
+				if net.connected {
+					net.session.Join(channel)
+				}
 			}
 		}
 
 	case "/part", "/leave":
-		channel := m.currentChannel
+		net := m.currentNetwork()
+		if net == nil || !net.connected {
+			break
+		}
+		channel := ""
+		if current, exists := m.channels[m.currentBuffer]; exists {
+			channel = current.name
+		}
 		if len(parts) >= 2 {
 			channel = parts[1]
 			if !strings.HasPrefix(channel, "#") {
 				channel = "#" + channel
 			}
 		}
-		if channel != "" && m.ircClient != nil {
-			m.ircClient.Part(channel)
-			m.setChannelJoined(channel, false)
+		if channel != "" {
+			net.session.Part(channel)
+			m.setChannelJoined(net.name, channel, false)
 		}
 
 	case "/nick":
-		if len(parts) >= 2 && m.ircClient != nil {
-			m.ircClient.Nick(parts[1])
+		if sess := m.currentSession(); len(parts) >= 2 && sess != nil {
+			sess.Nick(parts[1])
 		}
 
 	case "/quit":
-		if m.ircClient != nil {
-			reason := "Leaving"
-			if len(parts) >= 2 {
-				reason = strings.Join(parts[1:], " ")
+		reason := "Leaving"
+		if len(parts) >= 2 {
+			reason = strings.Join(parts[1:], " ")
+		}
+		m.quitAll(reason)
+
+	case "/disconnect":
+		reason := "Disconnecting"
+		if len(parts) >= 2 {
+			reason = strings.Join(parts[1:], " ")
+		}
+		if net := m.currentNetwork(); net != nil {
+			m.disconnect(net, reason)
+		}
+
+	case "/reconnect":
+		net := m.currentNetwork()
+		if net == nil {
+			break
+		}
+		if len(parts) >= 2 && strings.ToLower(parts[1]) == "now" {
+			return m.reconnectNow(net)
+		}
+		switch {
+		case net.connected:
+			m.addMessage(formatSystemMessage(m.networkLabel(net.name) + "Already connected to IRC server"))
+		case net.reconnect.waiting:
+			m.addMessage(formatSystemMessage(m.reconnectStatus(net)))
+		default:
+			return m.reconnectNow(net)
+		}
+
+	case "/names":
+		channel, exists := m.channels[m.currentBuffer]
+		if len(parts) >= 2 {
+			channel, exists = m.resolveBuffer(parts[1])
+		}
+		if exists && !channel.isQuery {
+			members := m.sortedMembers(channel)
+			m.addMessage(formatSystemMessage(fmt.Sprintf("Users in %s (%d): %s", channel.name, len(members), strings.Join(members, " "))))
+		} else if len(parts) >= 2 {
+			m.addMessage(formatErrorMessage(fmt.Sprintf("Channel %s not found", parts[1])))
+		} else {
+			m.addMessage(formatErrorMessage("/names only works in a channel"))
+		}
+
+	case "/topic":
+		channel, exists := m.channels[m.currentBuffer]
+		if !exists || channel.isQuery {
+			m.addMessage(formatErrorMessage("/topic only works in a channel"))
+			break
+		}
+		if len(parts) >= 2 {
+			if sess := m.currentSession(); sess != nil {
+				topic := expandFormatting(strings.TrimSpace(strings.TrimPrefix(input, parts[0])))
+				sess.SetTopic(channel.name, topic)
 			}
-			m.ircClient.Quit(reason)
+			break
+		}
+		topic := m.topic(channel)
+		if topic == "" {
+			m.addMessage(formatSystemMessage(fmt.Sprintf("No topic set for %s", channel.name)))
+			break
 		}
+		m.addMessage(formatTopicMessage(channel.name, topic))
+		if channel.topicSetBy != "" {
+			m.addMessage(formatTopicSetByMessage(channel.topicSetBy, channel.topicSetAt))
+		}
+
+	case "/nicklist":
+		m.showNicklist = !m.showNicklist
+		m.updateDimensions()
 
 	case "/switch", "/sw":
 		if len(parts) >= 2 {
 			channelName := parts[1]
-			if !strings.HasPrefix(channelName, "#") {
-				channelName = "#" + channelName
+			channel, exists := m.resolveBuffer(channelName)
+			if !exists && !strings.Contains(channelName, "/") && !strings.HasPrefix(channelName, "#") {
+				channel, exists = m.resolveBuffer("#" + channelName)
 			}
-			if channel, exists := m.channels[channelName]; exists && channel.joined {
-				m.switchToChannel(channelName)
+			if exists && channel.joined {
+				m.switchToChannel(channel.key())
 			} else {
 				m.addMessage(formatErrorMessage(fmt.Sprintf("Channel %s not found or not joined", channelName)))
 			}
 		} else {
 			// Show available channels
-			joinedChannels := m.getJoinedChannels()
+			joinedChannels := m.getOpenBuffers()
 			if len(joinedChannels) > 0 {
-				channelList := strings.Join(joinedChannels, ", ")
-				m.addMessage(formatSystemMessage(fmt.Sprintf("Available channels: %s", channelList)))
+				labels := make([]string, len(joinedChannels))
+				for i, key := range joinedChannels {
+					labels[i] = m.bufferLabel(key)
+				}
+				m.addMessage(formatSystemMessage(fmt.Sprintf("Available channels: %s", strings.Join(labels, ", "))))
 			} else {
 				m.addMessage(formatSystemMessage("No channels joined"))
 			}
 		}
 
 	case "/msg":
-		if len(parts) >= 3 && m.ircClient != nil {
+		net := m.currentNetwork()
+		if len(parts) >= 3 && net != nil && net.connected {
 			target := parts[1]
-			message := strings.Join(parts[2:], " ")
-			m.ircClient.Privmsg(target, message)
-			displayMsg := formatUserMessage(m.currentNick, message)
-			m.addMessage(displayMsg)
-			// Log the private message
-			m.logger.LogIRCMessage(target, m.currentNick, message)
+			message := expandFormatting(strings.Join(parts[2:], " "))
+			net.session.Privmsg(target, message)
+			if isSensitive(m.currentTarget(), input) {
+				message = maskCredentials(message)
+			}
+			displayMsg := formatOwnMessage(net.nick, message)
+			if !isChannelName(target) {
+				m.openQuery(net.name, target)
+			}
+			if channel, exists := m.findBuffer(net.name, target); exists {
+				m.addBufferMessage(channel.key(), displayMsg)
+			} else {
+				// A channel we are not in has no buffer, but the message is
+				// still logged under its name
+				displayMsg.Target = target
+				m.addMessage(displayMsg)
+				m.logBufferMessage(&channelData{network: net.name, name: target}, displayMsg)
+			}
+		}
+
+	case "/me":
+		if len(parts) < 2 {
+			m.addMessage(formatSystemMessage("Usage: /me <action>"))
+			break
+		}
+		channel, exists := m.channels[m.currentBuffer]
+		if net := m.currentNetwork(); exists && net.connected {
+			action := expandFormatting(strings.TrimSpace(strings.TrimPrefix(input, parts[0])))
+			net.session.Action(channel.name, action)
+			m.addBufferMessage(m.currentBuffer, formatActionMessage(net.nick, action))
+		}
+
+	case "/ctcp":
+		if len(parts) < 3 {
+			m.addMessage(formatSystemMessage("Usage: /ctcp <nick> <command> [args]"))
+			break
+		}
+		if sess := m.currentSession(); sess != nil {
+			ctcpCommand := strings.ToUpper(parts[2])
+			args := strings.Join(parts[3:], " ")
+			if ctcpCommand == "PING" && args == "" {
+				args = strconv.FormatInt(time.Now().UnixNano(), 10)
+			}
+			sess.Ctcp(parts[1], ctcpCommand, args)
+			m.addMessage(formatSystemMessage(fmt.Sprintf("Sent CTCP %s to %s", ctcpCommand, parts[1])))
+		}
+
+	case "/query", "/q":
+		net := m.currentNetwork()
+		if len(parts) < 2 || isChannelName(parts[1]) || net == nil {
+			m.addMessage(formatSystemMessage("Usage: /query <nick> [message]"))
+			break
+		}
+		query := m.openQuery(net.name, parts[1])
+		m.switchToChannel(query.key())
+		if len(parts) >= 3 && net.connected {
+			message := expandFormatting(strings.Join(parts[2:], " "))
+			net.session.Privmsg(query.name, message)
+			if isSensitive(m.currentTarget(), input) {
+				message = maskCredentials(message)
+			}
+			m.addBufferMessage(query.key(), formatOwnMessage(net.nick, message))
+		}
+
+	case "/close":
+		if len(parts) < 2 {
+			m.closeBuffer(m.currentBuffer)
+		} else if channel, ok := m.resolveBuffer(parts[1]); ok {
+			m.closeBuffer(channel.key())
+		} else {
+			m.addMessage(formatErrorMessage(fmt.Sprintf("No buffer named %s", parts[1])))
+		}
+
+	case "/networks":
+		for _, name := range m.networkOrder {
+			net := m.networks[name]
+			status := "disconnected"
+			switch {
+			case net.connected:
+				status = "connected as " + net.nick
+				if modes := net.userModes(); modes != "" {
+					status += " (+" + modes + ")"
+				}
+			case net.reconnect.waiting:
+				status = "reconnecting"
+			case net.connecting:
+				status = "connecting"
+			}
+			m.addMessage(formatSystemMessage(fmt.Sprintf("%s (%s): %s", name, net.config.Server, status)))
 		}
 
 	case "/config":
@@ -543,15 +1114,33 @@ func (m *model) handleCommand(input string) {
 				m.addMessage(formatSystemMessage(fmt.Sprintf("Nick: %s", m.config.IRC.Nick)))
 				m.addMessage(formatSystemMessage(fmt.Sprintf("Channels: %s", strings.Join(m.config.IRC.Channels, ", "))))
 				m.addMessage(formatSystemMessage(fmt.Sprintf("SSL: %v", m.config.IRC.UseSSL)))
+				if m.config.IRC.SASL.Enabled() {
+					m.addMessage(formatSystemMessage(fmt.Sprintf("SASL: %s (%s)", m.config.IRC.SASL.Mechanism, m.config.IRC.SASL.Account)))
+				}
+				for _, n := range m.config.Networks {
+					m.addMessage(formatSystemMessage(fmt.Sprintf("Network %s: %s as %s, channels %s", n.NetworkName(), n.Server, n.Nick, strings.Join(n.Channels, ", "))))
+				}
 				m.addMessage(formatSystemMessage(fmt.Sprintf("Logging: %v (Max: %d KB)", m.config.Logging.Enabled, m.config.Logging.MaxSizeKB)))
 			case "save":
 				m.saveConfig()
 				m.addMessage(formatSystemMessage("Configuration saved"))
 			case "reload":
-				if newConfig, err := LoadConfig(); err != nil {
+				if newConfig, err := m.reloadConfig(); err != nil {
 					m.addMessage(formatErrorMessage(fmt.Sprintf("Failed to reload config: %v", err)))
 				} else {
 					m.config = newConfig
+					m.loadedIRC = nil
+					highlighter, err := newHighlighter(newConfig.Highlight)
+					if err != nil {
+						m.addMessage(formatErrorMessage(err.Error()))
+					}
+					m.highlighter = highlighter
+					m.viewport.Strip = newConfig.UI.StripFormatting
+					m.ignores.set(newConfig.Ignores)
+					if err := m.setTheme(string(newConfig.UI.Theme)); err != nil {
+						m.addMessage(formatErrorMessage(err.Error()))
+					}
+					m.reflow()
 					m.addMessage(formatSystemMessage("Configuration reloaded"))
 				}
 			default:
@@ -589,21 +1178,100 @@ func (m *model) handleCommand(input string) {
 				} else {
 					m.addMessage(formatSystemMessage(fmt.Sprintf("Debug logging: %v", m.config.Logging.DebugMode)))
 				}
+			case "chat":
+				if len(parts) >= 3 {
+					switch strings.ToLower(parts[2]) {
+					case "on", "enable", "true":
+						m.config.Logging.ChatLogs = true
+						if m.config.Logging.ReplayLines == 0 {
+							m.config.Logging.ReplayLines = defaultReplayLines
+						}
+						m.saveConfig()
+						m.addMessage(formatSystemMessage("Chat logs enabled"))
+					case "off", "disable", "false":
+						m.config.Logging.ChatLogs = false
+						m.chatLog.Close()
+						m.saveConfig()
+						m.addMessage(formatSystemMessage("Chat logs disabled"))
+					default:
+						m.addMessage(formatSystemMessage("Usage: /logging chat [on|off]"))
+					}
+				} else {
+					m.addMessage(formatSystemMessage(fmt.Sprintf("Chat logs: %v (%s)", m.config.Logging.ChatLogs, m.config.GetChatLogDir())))
+				}
 			case "status", "show":
 				m.addMessage(formatSystemMessage(fmt.Sprintf("Logging: %v", m.config.Logging.Enabled)))
+				m.addMessage(formatSystemMessage(fmt.Sprintf("Chat logs: %v (replaying %d lines)", m.config.Logging.ChatLogs, m.config.Logging.ReplayLines)))
 				m.addMessage(formatSystemMessage(fmt.Sprintf("Debug: %v", m.config.Logging.DebugMode)))
 				m.addMessage(formatSystemMessage(fmt.Sprintf("Log path: %s", m.config.Logging.LogPath)))
 				m.addMessage(formatSystemMessage(fmt.Sprintf("Max size: %d KB", m.config.Logging.MaxSizeKB)))
 			default:
-				m.addMessage(formatSystemMessage("Usage: /logging [on|off|debug on|off|status]"))
+				m.addMessage(formatSystemMessage("Usage: /logging [on|off|chat on|off|debug on|off|status]"))
 			}
 		} else {
 			m.addMessage(formatSystemMessage(fmt.Sprintf("Logging: %v (use '/logging on' or '/logging off' to toggle)", m.config.Logging.Enabled)))
 		}
 
+	case "/ignore":
+		if len(parts) < 2 {
+			m.showIgnores()
+			break
+		}
+		m.handleIgnoreCommand(parts[1:])
+
+	case "/unignore":
+		if len(parts) < 2 {
+			m.addMessage(formatSystemMessage("Usage: /unignore <mask|number>"))
+			break
+		}
+		m.ignores.list() // Drop expired entries so numbers match /ignores
+		if entry, ok := m.ignores.remove(parts[1]); ok {
+			m.saveIgnores()
+			m.addMessage(formatSystemMessage("No longer ignoring " + entry.Mask))
+		} else {
+			m.addMessage(formatErrorMessage(fmt.Sprintf("%s is not ignored", parts[1])))
+		}
+
+	case "/ignores":
+		m.showIgnores()
+
+	case "/filter":
+		m.handleFilterCommand(parts[1:])
+
+	case "/theme":
+		m.handleThemeCommand(parts[1:])
+
+	case "/formatting", "/format":
+		if len(parts) < 2 {
+			state := "shown"
+			if m.config.UI.StripFormatting {
+				state = "stripped"
+			}
+			m.addMessage(formatSystemMessage(fmt.Sprintf("Formatting: %s (use '/formatting show' or '/formatting strip' to change)", state)))
+			break
+		}
+		switch strings.ToLower(parts[1]) {
+		case "show", "on":
+			m.config.UI.StripFormatting = false
+		case "strip", "off":
+			m.config.UI.StripFormatting = true
+		default:
+			m.addMessage(formatSystemMessage("Usage: /formatting [show|strip]"))
+			return nil
+		}
+		m.viewport.Strip = m.config.UI.StripFormatting
+		m.reflow()
+		m.saveConfig()
+		if m.config.UI.StripFormatting {
+			m.addMessage(formatSystemMessage("Formatting codes are now stripped"))
+		} else {
+			m.addMessage(formatSystemMessage("Formatting codes are now shown"))
+		}
+
 	default:
 		m.addMessage(formatErrorMessage("Unknown command: " + command))
 	}
+	return nil
 }
 
 func (m *model) showSetupHelp() {
@@ -632,7 +1300,28 @@ func (m *model) saveConfig() error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	return m.config.Save()
+	if m.loadedIRC == nil {
+		return m.config.Save()
+	}
+	// Servers, nicks and channels given on the command line are for this
+	// run only; everything else changed since is saved as usual
+	config := *m.config
+	config.IRC.Server = m.loadedIRC.Server
+	config.IRC.Port = m.loadedIRC.Port
+	config.IRC.UseSSL = m.loadedIRC.UseSSL
+	config.IRC.Nick = m.loadedIRC.Nick
+	config.IRC.Channels = m.loadedIRC.Channels
+	err := config.Save()
+	m.config.FilePath = config.FilePath
+	return err
+}
+
+// reloadConfig reads the config again from the file it was loaded from
+func (m *model) reloadConfig() (*Config, error) {
+	if m.config.FilePath == "" {
+		return LoadConfig()
+	}
+	return LoadConfigFile(m.config.FilePath)
 }
 
 // Validation methods for setup wizard
@@ -724,18 +1413,23 @@ func initCommandPaletteItems() []commandPaletteItem {
 		// Channel Management
 		{name: "Join Channel", description: "Join a new IRC channel", command: "/join", category: "Channels", icon: "+", shortcut: "", priority: 90},
 		{name: "Part Channel", description: "Leave the current channel", command: "/part", category: "Channels", icon: "-", shortcut: "", priority: 80},
-		{name: "Switch Channel", description: "Switch to a different channel", command: "/switch", category: "Channels", icon: "~", shortcut: "Tab", priority: 95},
-		{name: "Next Channel", description: "Switch to the next channel", command: "next_channel", category: "Navigation", icon: ">", shortcut: "Tab", priority: 85},
-		{name: "Previous Channel", description: "Switch to the previous channel", command: "prev_channel", category: "Navigation", icon: "<", shortcut: "Shift+Tab", priority: 85},
+		{name: "Switch Channel", description: "Switch to a different channel", command: "/switch", category: "Channels", icon: "~", shortcut: "", priority: 95},
+		{name: "Next Channel", description: "Switch to the next channel", command: "next_channel", category: "Navigation", icon: ">", shortcut: "Alt+Right", priority: 85},
+		{name: "Previous Channel", description: "Switch to the previous channel", command: "prev_channel", category: "Navigation", icon: "<", shortcut: "Alt+Left", priority: 85},
+		{name: "Close Buffer", description: "Close the current query or channel", command: "/close", category: "Channels", icon: "x", shortcut: "", priority: 62},
 		{name: "List Channels", description: "Show all joined channels", command: "list_channels", category: "Channels", icon: "=", shortcut: "", priority: 65},
 
 		// Communication
 		{name: "Send Private Message", description: "Send a private message to a user", command: "/msg", category: "Communication", icon: "@", shortcut: "", priority: 75},
+		{name: "Show Topic", description: "Show the current channel topic", command: "/topic", category: "Channels", icon: "t", shortcut: "", priority: 64},
 		{name: "Change Nickname", description: "Change your nickname", command: "/nick", category: "User", icon: "*", shortcut: "", priority: 70},
+		{name: "List Ignores", description: "Show ignored users and masks", command: "/ignores", category: "User", icon: "~", shortcut: "", priority: 48},
 
 		// Interface
 		{name: "Toggle Sidebar", description: "Show/hide the channel sidebar", command: "toggle_sidebar", category: "Interface", icon: "|", shortcut: "Ctrl+B", priority: 60},
 		{name: "Command Palette", description: "Open command palette", command: "command_palette", category: "Interface", icon: ".", shortcut: "Ctrl+P", priority: 100},
+		{name: "Toggle User List", description: "Show/hide the channel user list", command: "toggle_nicklist", category: "Interface", icon: "@", shortcut: "Ctrl+L", priority: 58},
+		{name: "List Themes", description: "Show the color themes to pick with /theme", command: "/theme list", category: "Interface", icon: "%", shortcut: "", priority: 54},
 		{name: "Clear Screen", description: "Clear the chat messages", command: "clear_screen", category: "Interface", icon: "x", shortcut: "", priority: 55},
 
 		// Information
@@ -759,16 +1453,17 @@ func (m *model) getDynamicCommandPaletteItems() []commandPaletteItem {
 	var dynamicItems []commandPaletteItem
 
 	// Add channel-specific commands based on joined channels
-	if m.connected {
+	if m.isConnected() {
 		joinedChannels := m.getJoinedChannels()
 
 		// Add switch commands for each joined channel
-		for _, channel := range joinedChannels {
-			if channel != m.currentChannel {
+		for _, key := range joinedChannels {
+			if key != m.currentBuffer {
+				label := m.bufferLabel(key)
 				dynamicItems = append(dynamicItems, commandPaletteItem{
-					name:        "Switch to " + channel,
-					description: "Switch to channel " + channel,
-					command:     "/switch " + channel,
+					name:        "Switch to " + label,
+					description: "Switch to channel " + label,
+					command:     "/switch " + label,
 					category:    "Quick Switch",
 					icon:        "~",
 					shortcut:    "",
@@ -777,12 +1472,22 @@ func (m *model) getDynamicCommandPaletteItems() []commandPaletteItem {
 			}
 		}
 
-		// Add part command for current channel
-		if m.currentChannel != "" {
+		// Add part command for current channel, or close for a query
+		if channel, exists := m.channels[m.currentBuffer]; exists && channel.isQuery {
+			dynamicItems = append(dynamicItems, commandPaletteItem{
+				name:        "Close " + channel.name,
+				description: "Close the query with " + channel.name,
+				command:     "/close " + channel.name,
+				category:    "Current Channel",
+				icon:        "x",
+				shortcut:    "",
+				priority:    85,
+			})
+		} else if exists {
 			dynamicItems = append(dynamicItems, commandPaletteItem{
-				name:        "Part " + m.currentChannel,
-				description: "Leave channel " + m.currentChannel,
-				command:     "/part " + m.currentChannel,
+				name:        "Part " + channel.name,
+				description: "Leave channel " + channel.name,
+				command:     "/part " + channel.name,
 				category:    "Current Channel",
 				icon:        "🚪",
 				shortcut:    "",
@@ -790,6 +1495,16 @@ func (m *model) getDynamicCommandPaletteItems() []commandPaletteItem {
 			})
 		}
 
+		dynamicItems = append(dynamicItems, commandPaletteItem{
+			name:        "Disconnect",
+			description: "Disconnect without reconnecting",
+			command:     "/disconnect",
+			category:    "Connection",
+			icon:        "x",
+			shortcut:    "",
+			priority:    20,
+		})
+
 		// Add reconnect command if disconnected
 	} else {
 		dynamicItems = append(dynamicItems, commandPaletteItem{
@@ -806,8 +1521,10 @@ func (m *model) getDynamicCommandPaletteItems() []commandPaletteItem {
 	// Add commonly used channels for quick joining
 	commonChannels := []string{"#general", "#help", "#random", "#dev", "#announcements"}
 	joinedChannelMap := make(map[string]bool)
-	for _, ch := range m.getJoinedChannels() {
-		joinedChannelMap[ch] = true
+	if net := m.currentNetwork(); net != nil {
+		for _, key := range m.networkChannels(net.name) {
+			joinedChannelMap[m.channels[key].name] = true
+		}
 	}
 
 	for _, channel := range commonChannels {
@@ -947,6 +1664,10 @@ func (m *model) executeCommandPaletteItem(item commandPaletteItem) tea.Cmd {
 		m.updateDimensions()
 		m.addMessage(formatSystemMessage("Sidebar toggled"))
 
+	case "toggle_nicklist":
+		m.showNicklist = !m.showNicklist
+		m.updateDimensions()
+
 	case "next_channel":
 		m.nextChannel()
 
@@ -960,44 +1681,42 @@ func (m *model) executeCommandPaletteItem(item commandPaletteItem) tea.Cmd {
 		m.filterCommandPalette()
 
 	case "reconnect":
-		if !m.connected {
-			m.state = stateConnecting
-			return m.connectToIRC()
-		} else {
-			m.addMessage(formatSystemMessage("Already connected to IRC server"))
+		if net := m.currentNetwork(); net != nil {
+			return m.reconnectNow(net)
 		}
 
 	case "list_channels":
 		joinedChannels := m.getJoinedChannels()
 		if len(joinedChannels) > 0 {
 			m.addMessage(formatSystemMessage("📋 Joined Channels:"))
-			for i, channel := range joinedChannels {
+			for i, key := range joinedChannels {
 				indicator := "  "
-				if channel == m.currentChannel {
+				if key == m.currentBuffer {
 					indicator = "➤ "
 				}
-				m.addMessage(formatSystemMessage(fmt.Sprintf("%s%d. %s", indicator, i+1, channel)))
+				m.addMessage(formatSystemMessage(fmt.Sprintf("%s%d. %s", indicator, i+1, m.bufferLabel(key))))
 			}
 		} else {
 			m.addMessage(formatSystemMessage("No channels joined"))
 		}
 
 	case "clear_screen":
-		m.messages = []string{}
-		if m.currentChannel != "" {
-			if channel, exists := m.channels[m.currentChannel]; exists {
-				channel.messages = []string{}
-			}
+		m.messages.clear()
+		if channel, exists := m.channels[m.currentBuffer]; exists {
+			channel.messages.clear()
 		}
+		m.viewport.reset()
+		m.newBelow = 0
 		m.addMessage(formatSystemMessage("Screen cleared"))
 
 	case "connection_status":
-		if m.connected {
-			uptime := time.Since(m.connectionTime).Truncate(time.Second)
+		if net := m.currentNetwork(); net != nil && net.connected {
+			uptime := now().Sub(net.connectionTime).Truncate(time.Second)
 			m.addMessage(formatSystemMessage("🔌 Connection Status: Connected"))
-			m.addMessage(formatSystemMessage(fmt.Sprintf("Server: %s", m.config.IRC.Server)))
-			m.addMessage(formatSystemMessage(fmt.Sprintf("Nickname: %s", m.currentNick)))
-			m.addMessage(formatSystemMessage(fmt.Sprintf("Current Channel: %s", m.currentChannel)))
+			m.addMessage(formatSystemMessage(fmt.Sprintf("Network: %s (%d/%d connected)", net.name, m.connectedCount(), len(m.networkOrder))))
+			m.addMessage(formatSystemMessage(fmt.Sprintf("Server: %s", net.config.Server)))
+			m.addMessage(formatSystemMessage(fmt.Sprintf("Nickname: %s", net.nick)))
+			m.addMessage(formatSystemMessage(fmt.Sprintf("Current Channel: %s", m.bufferLabel(m.currentBuffer))))
 			m.addMessage(formatSystemMessage(fmt.Sprintf("Uptime: %v", uptime)))
 		} else {
 			m.addMessage(formatSystemMessage("🔌 Connection Status: Disconnected"))
@@ -1008,31 +1727,29 @@ func (m *model) executeCommandPaletteItem(item commandPaletteItem) tea.Cmd {
 			// Execute the command directly instead of just setting it in textarea
 			switch {
 			case item.command == "/help":
-				m.handleCommand("/help")
+				return m.handleCommand("/help")
 
 			case item.command == "/quit":
-				if m.ircClient != nil {
-					m.ircClient.Quit("Leaving via command palette")
-				}
+				m.quitAll("Leaving via command palette")
 				return tea.Quit
 
 			case strings.HasPrefix(item.command, "/config"):
-				m.handleCommand(item.command)
+				return m.handleCommand(item.command)
 
 			case strings.HasPrefix(item.command, "/logging"):
-				m.handleCommand(item.command)
+				return m.handleCommand(item.command)
 
 			case strings.HasPrefix(item.command, "/switch "):
 				// Handle dynamic switch commands
-				m.handleCommand(item.command)
+				return m.handleCommand(item.command)
 
 			case strings.HasPrefix(item.command, "/join "):
 				// Handle dynamic join commands
-				m.handleCommand(item.command)
+				return m.handleCommand(item.command)
 
 			case strings.HasPrefix(item.command, "/part "):
 				// Handle dynamic part commands
-				m.handleCommand(item.command)
+				return m.handleCommand(item.command)
 
 			case item.command == "/join":
 				// For commands that need user input, set them in textarea with a space
@@ -1041,8 +1758,8 @@ func (m *model) executeCommandPaletteItem(item commandPaletteItem) tea.Cmd {
 
 			case item.command == "/part":
 				// Part current channel if no arguments, otherwise set in textarea
-				if m.currentChannel != "" {
-					m.handleCommand("/part " + m.currentChannel)
+				if channel, exists := m.channels[m.currentBuffer]; exists && !channel.isQuery {
+					return m.handleCommand("/part " + channel.name)
 				} else {
 					m.textarea.SetValue(item.command + " ")
 					m.textarea.Focus()
@@ -1052,9 +1769,13 @@ func (m *model) executeCommandPaletteItem(item commandPaletteItem) tea.Cmd {
 				// Show available channels or set in textarea for manual input
 				joinedChannels := m.getJoinedChannels()
 				if len(joinedChannels) > 1 {
-					channelList := strings.Join(joinedChannels, ", ")
+					labels := make([]string, len(joinedChannels))
+					for i, key := range joinedChannels {
+						labels[i] = m.bufferLabel(key)
+					}
+					channelList := strings.Join(labels, ", ")
 					m.addMessage(formatSystemMessage("Available channels: " + channelList))
-					m.addMessage(formatSystemMessage("Use /switch <channel> or Tab/Shift+Tab to navigate"))
+					m.addMessage(formatSystemMessage("Use /switch <channel> or Alt+Left/Alt+Right to navigate"))
 				}
 				m.textarea.SetValue(item.command + " ")
 				m.textarea.Focus()
@@ -1069,7 +1790,7 @@ func (m *model) executeCommandPaletteItem(item commandPaletteItem) tea.Cmd {
 
 			default:
 				// For any other IRC command, execute it directly
-				m.handleCommand(item.command)
+				return m.handleCommand(item.command)
 			}
 		}
 	}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"goirc/session"
+)
+
+// network is one configured server: its session and the view state that
+// goes with it
+type network struct {
+	name           string
+	config         IRCConfig
+	session        *session.Session
+	nick           string
+	connected      bool
+	connecting     bool // Dialing or registering
+	connectionTime time.Time
+
+	reconnect reconnectState // Automatic reconnect supervisor
+	splits    netsplits      // Netsplits being summarised
+}
+
+// modes is the server's membership prefix and channel mode table
+func (net *network) modes() session.Modes {
+	if net.session == nil {
+		return session.DefaultModes()
+	}
+	return net.session.Modes()
+}
+
+// userModes are our user modes without the leading +, e.g. "iZ"
+func (net *network) userModes() string {
+	if net.session == nil {
+		return ""
+	}
+	return net.session.UserModes()
+}
+
+// initNetworks builds one network per configured server, replacing any
+// left over from an earlier session
+func (m *model) initNetworks() error {
+	if err := m.config.Validate(); err != nil {
+		return err
+	}
+	m.highlighter, _ = newHighlighter(m.config.Highlight)
+
+	m.networks = make(map[string]*network)
+	m.networkOrder = nil
+	for _, cfg := range m.config.AllNetworks() {
+		name := cfg.NetworkName()
+		m.networks[name] = &network{
+			name:   name,
+			config: cfg,
+			nick:   cfg.Nick,
+		}
+		m.networkOrder = append(m.networkOrder, name)
+	}
+	return nil
+}
+
+// network looks up a network by name, returning nil if it is unknown
+func (m *model) network(name string) *network {
+	return m.networks[name]
+}
+
+// currentNetwork is the network of the buffer in view, or the first one when
+// no buffer is open. Commands act on this network.
+func (m *model) currentNetwork() *network {
+	if channel, exists := m.channels[m.currentBuffer]; exists {
+		if net := m.network(channel.network); net != nil {
+			return net
+		}
+	}
+	if len(m.networkOrder) > 0 {
+		return m.networks[m.networkOrder[0]]
+	}
+	return nil
+}
+
+// currentSession returns the session of the current network, if connected
+func (m *model) currentSession() *session.Session {
+	if net := m.currentNetwork(); net != nil && net.connected {
+		return net.session
+	}
+	return nil
+}
+
+// isConnected reports whether the current network is connected
+func (m *model) isConnected() bool {
+	net := m.currentNetwork()
+	return net != nil && net.connected
+}
+
+// connectedCount returns how many networks are connected
+func (m *model) connectedCount() int {
+	count := 0
+	for _, net := range m.networks {
+		if net.connected {
+			count++
+		}
+	}
+	return count
+}
+
+// connectAll dials every configured network
+func (m *model) connectAll() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, name := range m.networkOrder {
+		net := m.networks[name]
+		net.connecting = true
+		cmds = append(cmds, m.connectToIRC(net))
+	}
+	return tea.Batch(cmds...)
+}
+
+// networkLabel prefixes network-wide messages with the network name once
+// more than one network is configured, so it is clear where they came from
+func (m *model) networkLabel(name string) string {
+	if len(m.networkOrder) <= 1 {
+		return ""
+	}
+	return "[" + name + "] "
+}
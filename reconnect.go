@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	reconnectBaseDelay = 2 * time.Second
+	reconnectMaxDelay  = 5 * time.Minute
+)
+
+// reconnectState tracks the automatic reconnect supervisor
+type reconnectState struct {
+	active   bool      // Supervisor is running after an unexpected disconnect
+	waiting  bool      // Counting down to the next attempt
+	attempt  int       // Number of attempts made so far
+	nextTry  time.Time // When the next attempt fires
+	gen      int       // Invalidates ticks from earlier countdowns
+	stopped  bool      // User asked to stay disconnected (/disconnect)
+	quitting bool      // User quit; return to setup instead of reconnecting
+}
+
+type reconnectTickMsg struct {
+	network string
+	gen     int
+}
+
+// reconnectDelay returns a jittered exponential backoff for the given attempt
+func reconnectDelay(attempt int) time.Duration {
+	delay := reconnectMaxDelay
+	if attempt < 16 {
+		delay = min(reconnectBaseDelay<<attempt, reconnectMaxDelay)
+	}
+	// Equal jitter: half fixed, half random
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func reconnectTick(network string, gen int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return reconnectTickMsg{network: network, gen: gen}
+	})
+}
+
+// scheduleReconnect starts the countdown to the next reconnect attempt
+func (m *model) scheduleReconnect(net *network) tea.Cmd {
+	delay := reconnectDelay(net.reconnect.attempt)
+	net.reconnect.active = true
+	net.reconnect.waiting = true
+	net.reconnect.nextTry = now().Add(delay)
+	net.reconnect.gen++
+	if m.connectedCount() == 0 {
+		m.state = stateConnecting
+	}
+
+	m.logger.LogIRCEvent("Reconnecting to %s in %v (attempt %d)", net.name, delay.Truncate(time.Second), net.reconnect.attempt+1)
+	m.addMessage(formatSystemMessage(fmt.Sprintf("%sReconnecting in %v (attempt %d)...", m.networkLabel(net.name), delay.Truncate(time.Second), net.reconnect.attempt+1)))
+	return reconnectTick(net.name, net.reconnect.gen)
+}
+
+// reconnectNow skips any countdown and dials the server immediately
+func (m *model) reconnectNow(net *network) tea.Cmd {
+	if net.connected {
+		m.addMessage(formatSystemMessage(m.networkLabel(net.name) + "Already connected to IRC server"))
+		return nil
+	}
+	// A second dial would leave two sessions racing for the network
+	if net.connecting {
+		m.addMessage(formatSystemMessage(m.networkLabel(net.name) + "Already connecting to IRC server"))
+		return nil
+	}
+	net.reconnect.active = true
+	net.reconnect.waiting = false
+	net.reconnect.stopped = false
+	net.reconnect.attempt++
+	net.reconnect.gen++
+	net.connecting = true
+	if m.connectedCount() == 0 {
+		m.state = stateConnecting
+	}
+	m.addMessage(formatSystemMessage(fmt.Sprintf("%sReconnecting to %s...", m.networkLabel(net.name), net.config.Server)))
+	return m.connectToIRC(net)
+}
+
+// handleReconnectTick advances the countdown shown in the status bar
+func (m *model) handleReconnectTick(msg reconnectTickMsg) tea.Cmd {
+	net := m.network(msg.network)
+	if net == nil || !net.reconnect.waiting || msg.gen != net.reconnect.gen {
+		return nil
+	}
+	if now().Before(net.reconnect.nextTry) {
+		return reconnectTick(net.name, msg.gen)
+	}
+	return m.reconnectNow(net)
+}
+
+// handleDisconnect decides what happens after a network's connection drops
+func (m *model) handleDisconnect(net *network) tea.Cmd {
+	net.connected = false
+	net.connecting = false
+	net.session = nil
+	// The member lists and topics went with the session
+	for _, key := range m.networkChannels(net.name) {
+		channel := m.channels[key]
+		channel.members, channel.nicklist = nil, nil
+		channel.topic, channel.topicSetBy, channel.topicSetAt = "", "", time.Time{}
+	}
+	m.addMessage(formatErrorMessage(m.networkLabel(net.name) + "Disconnected from IRC server"))
+
+	switch {
+	case net.reconnect.quitting:
+		net.reconnect = reconnectState{}
+		// Nothing to rejoin next session; channels are joined from the config
+		for _, key := range m.networkChannels(net.name) {
+			m.channels[key].joined = false
+		}
+		if m.allQuit() {
+			m.state = stateSetup
+			m.updateDimensions()
+		}
+		return nil
+	case net.reconnect.stopped:
+		net.reconnect.active = false
+		net.reconnect.waiting = false
+		m.state = stateConnected
+		m.addMessage(formatSystemMessage(m.networkLabel(net.name) + "Use /reconnect to connect again"))
+		return nil
+	}
+	return m.scheduleReconnect(net)
+}
+
+// allQuit reports whether every network has been quit and is now idle
+func (m *model) allQuit() bool {
+	for _, net := range m.networks {
+		if net.connected || net.connecting || net.reconnect.quitting || net.reconnect.waiting {
+			return false
+		}
+	}
+	return true
+}
+
+// disconnect stops a network's reconnect supervisor and closes its connection
+func (m *model) disconnect(net *network, reason string) {
+	net.reconnect.stopped = true
+	net.reconnect.gen++
+	if net.connected && net.session != nil {
+		net.session.Quit(reason)
+		return
+	}
+	if net.connecting {
+		// Abandon the attempt. A session still being dialed is closed when
+		// it arrives; either way its Disconnected finishes the job.
+		if net.session != nil {
+			net.session.Close()
+		}
+		m.addMessage(formatSystemMessage(m.networkLabel(net.name) + "Connection attempt cancelled"))
+		return
+	}
+	if net.reconnect.waiting {
+		net.reconnect.active = false
+		net.reconnect.waiting = false
+		m.state = stateConnected
+		m.addMessage(formatSystemMessage(m.networkLabel(net.name) + "Reconnect cancelled"))
+	}
+}
+
+// quitAll quits every connected network; the client returns to setup once
+// the last one has gone
+func (m *model) quitAll(reason string) {
+	for _, name := range m.networkOrder {
+		net := m.networks[name]
+		net.reconnect.gen++
+		net.reconnect.waiting = false
+		if net.connected && net.session != nil {
+			net.reconnect.quitting = true
+			net.session.Quit(reason)
+		}
+	}
+	if m.allQuit() {
+		m.state = stateSetup
+		m.updateDimensions()
+	}
+}
+
+// joinChannels joins the configured channels on the first connection and,
+// after a reconnect, every buffer that was joined when the connection dropped
+// so their scrollback carries on where it left off
+func (m *model) joinChannels(net *network, reconnecting bool) {
+	if net.session == nil {
+		return
+	}
+	if !reconnecting {
+		for _, channel := range net.config.Channels {
+			if channel != "" {
+				m.autoJoinChannels = append(m.autoJoinChannels, channel)
+				m.addChannel(net.name, channel)
+				net.session.Join(channel)
+				m.logger.LogIRCEvent("Joining channel %s on %s", channel, net.name)
+			}
+		}
+		return
+	}
+	for _, key := range m.networkChannels(net.name) {
+		channelName := m.channels[key].name
+		net.session.Join(channelName)
+		m.logger.LogIRCEvent("Rejoining channel %s on %s", channelName, net.name)
+	}
+}
+
+// reconnectStatus describes a network's countdown for the status bar
+func (m *model) reconnectStatus(net *network) string {
+	remaining := net.reconnect.nextTry.Sub(now()).Round(time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return fmt.Sprintf("%sReconnecting in %v (attempt %d) | /reconnect now to retry | /disconnect to cancel",
+		m.networkLabel(net.name), remaining, net.reconnect.attempt+1)
+}
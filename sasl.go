@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	sasl "github.com/emersion/go-sasl"
+
+	"goirc/session"
+)
+
+// Supported SASL mechanisms
+const (
+	saslPlain       = "PLAIN"
+	saslExternal    = "EXTERNAL"
+	saslScramSHA256 = "SCRAM-SHA-256"
+)
+
+// Enabled reports whether SASL authentication has been configured
+func (s SASLConfig) Enabled() bool {
+	return s.Mechanism != ""
+}
+
+// newSASLClient builds the goirc SASL client for the configured mechanism
+func newSASLClient(s SASLConfig) (sasl.Client, error) {
+	switch strings.ToUpper(s.Mechanism) {
+	case saslPlain:
+		return sasl.NewPlainClient("", s.Account, s.Password), nil
+	case saslExternal:
+		return sasl.NewExternalClient(""), nil
+	case saslScramSHA256:
+		return session.NewSCRAMClient(s.Account, s.Password)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism: %s", s.Mechanism)
+	}
+}
+
+// loadClientCertificate loads the TLS client certificate used by SASL EXTERNAL.
+// KeyFile may be left empty when the certificate file also contains the key.
+func loadClientCertificate(s SASLConfig) (tls.Certificate, error) {
+	keyFile := s.KeyFile
+	if keyFile == "" {
+		keyFile = s.CertFile
+	}
+	cert, err := tls.LoadX509KeyPair(s.CertFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	return cert, nil
+}
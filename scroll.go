@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// scrollState is a buffer's read position, kept while it is out of view
+type scrollState struct {
+	offset   int  // Lines scrolled up from the bottom when we left the buffer
+	scrolled bool // The user had scrolled up; false means follow new messages
+	newBelow int  // Messages below the read position not yet seen
+	arrived  int  // Messages added since we left the buffer
+}
+
+// handleScrollKey moves the chat view for PgUp/PgDn and Home/End. Home and
+// End only scroll when the input is empty, otherwise they move the cursor;
+// Ctrl+Home and Ctrl+End always scroll. It reports whether the key was used.
+func (m *model) handleScrollKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyPgUp:
+		m.viewport.PageUp()
+	case tea.KeyPgDown:
+		m.viewport.PageDown()
+	case tea.KeyCtrlHome:
+		m.viewport.GotoTop()
+	case tea.KeyCtrlEnd:
+		m.viewport.GotoBottom()
+	case tea.KeyHome:
+		if m.textarea.Value() != "" {
+			return false
+		}
+		m.viewport.GotoTop()
+	case tea.KeyEnd:
+		if m.textarea.Value() != "" {
+			return false
+		}
+		m.viewport.GotoBottom()
+	default:
+		return false
+	}
+	m.syncScroll()
+	return true
+}
+
+// syncScroll clears the new-messages marker once the bottom is in view again
+func (m *model) syncScroll() {
+	if m.viewport.AtBottom() {
+		m.newBelow = 0
+	}
+}
+
+// scrollToBottom jumps to the latest message, e.g. after sending one
+func (m *model) scrollToBottom() {
+	m.viewport.GotoBottom()
+	m.newBelow = 0
+}
+
+// saveScroll remembers the read position of the buffer leaving the view
+func (m *model) saveScroll(key string) {
+	channel, exists := m.channels[key]
+	if !exists {
+		return
+	}
+	channel.scroll = scrollState{
+		offset:   m.viewport.offset,
+		scrolled: !m.viewport.AtBottom(),
+		newBelow: m.newBelow,
+	}
+}
+
+// restoreScroll puts the view back where the buffer was left; buffers that
+// were following new messages open at the bottom
+func (m *model) restoreScroll(channel *channelData) {
+	if !channel.scroll.scrolled {
+		m.scrollToBottom()
+		return
+	}
+	// Messages that arrived meanwhile were added below the read position
+	m.newBelow = channel.scroll.newBelow
+	m.viewport.SetOffset(channel.scroll.offset + m.viewport.linesBelow(channel.scroll.arrived))
+	m.syncScroll()
+}
+
+// newMessagesMarker replaces the last visible chat line with a count of the
+// messages waiting below while the user is scrolled up
+func (m *model) newMessagesMarker(chatContent string) string {
+	if m.newBelow == 0 || m.viewport.AtBottom() {
+		return chatContent
+	}
+	noun := "messages"
+	if m.newBelow == 1 {
+		noun = "message"
+	}
+	marker := fmt.Sprintf("▼ %d new %s below (PgDn/End to read)", m.newBelow, noun)
+	marker = newMessagesStyle.Render(ansi.Truncate(marker, m.viewport.Width, "…"))
+
+	lines := strings.Split(chatContent, "\n")
+	lines[len(lines)-1] = marker
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const defaultScrollbackLines = 5000
+
+// messageRing is a buffer's history capped at a fixed number of messages;
+// once full, each new message drops the oldest
+type messageRing struct {
+	items []Message
+	start int    // Index of the oldest message in items once full
+	first uint64 // Sequence number of the oldest message
+	limit int
+}
+
+func newMessageRing(limit int) *messageRing {
+	if limit <= 0 {
+		limit = defaultScrollbackLines
+	}
+	return &messageRing{limit: limit}
+}
+
+// push appends a message, dropping the oldest when the ring is full
+func (r *messageRing) push(msg Message) {
+	if len(r.items) < r.limit {
+		r.items = append(r.items, msg)
+		return
+	}
+	r.items[r.start] = msg
+	r.start = (r.start + 1) % r.limit
+	r.first++
+}
+
+func (r *messageRing) len() int {
+	return len(r.items)
+}
+
+// at returns the i-th message, oldest first
+func (r *messageRing) at(i int) Message {
+	return r.items[(r.start+i)%len(r.items)]
+}
+
+// seq is the sequence number of the i-th message; it stays the same as
+// older messages are dropped, so it can key render caches
+func (r *messageRing) seq(i int) uint64 {
+	return r.first + uint64(i)
+}
+
+// all copies the messages out, oldest first
+func (r *messageRing) all() []Message {
+	messages := make([]Message, 0, len(r.items))
+	for i := range r.items {
+		messages = append(messages, r.at(i))
+	}
+	return messages
+}
+
+// replace swaps the contents for a copy of another ring
+func (r *messageRing) replace(other *messageRing) {
+	r.items = other.all()
+	r.start = 0
+	r.first = other.first
+	if r.limit < len(r.items) {
+		r.limit = len(r.items)
+	}
+}
+
+func (r *messageRing) clear() {
+	r.first += uint64(len(r.items))
+	r.items = nil
+	r.start = 0
+}
+
+// chatView draws the tail of a message ring. Only the messages on screen
+// are wrapped and rendered, and each is rendered once per width, so the
+// cost of a frame does not grow with the history.
+type chatView struct {
+	Width  int // Columns messages are wrapped to
+	Height int
+	Strip  bool // Drop mIRC formatting instead of drawing it
+
+	source *messageRing
+	offset int // Lines scrolled up from the bottom; 0 follows new messages
+
+	cache      map[uint64][]string // Rendered lines by message sequence
+	cacheWidth int
+}
+
+func newChatView(source *messageRing, width, height int) chatView {
+	return chatView{Width: width, Height: height, source: source}
+}
+
+// lines returns the rendered lines of the i-th message
+func (v *chatView) lines(i int) []string {
+	if v.cache == nil || v.cacheWidth != v.Width {
+		v.cache = make(map[uint64][]string)
+		v.cacheWidth = v.Width
+	}
+	seq := v.source.seq(i)
+	if lines, ok := v.cache[seq]; ok {
+		return lines
+	}
+	lines := strings.Split(v.source.at(i).render(v.Width, v.Strip), "\n")
+	v.cache[seq] = lines
+	return lines
+}
+
+// invalidate drops rendered lines, e.g. after the theme or formatting
+// setting changes
+func (v *chatView) invalidate() {
+	v.cache = nil
+}
+
+// reset forgets cached lines and scrolls to the bottom after the source
+// was replaced by another buffer's history
+func (v *chatView) reset() {
+	v.cache = nil
+	v.offset = 0
+}
+
+// appended keeps the view in place when a message arrives while scrolled
+// up; at the bottom it simply follows
+func (v *chatView) appended() {
+	if v.offset > 0 {
+		v.offset += len(v.lines(v.source.len() - 1))
+	}
+	if len(v.cache) > 2*v.source.limit {
+		v.pruneCache()
+	}
+}
+
+// pruneCache drops lines cached for messages the ring has since dropped
+func (v *chatView) pruneCache() {
+	for seq := range v.cache {
+		if seq < v.source.first {
+			delete(v.cache, seq)
+		}
+	}
+}
+
+// linesBelow counts the lines of the newest n messages
+func (v *chatView) linesBelow(n int) int {
+	total := 0
+	for i := v.source.len() - 1; i >= 0 && n > 0; i, n = i-1, n-1 {
+		total += len(v.lines(i))
+	}
+	return total
+}
+
+// clamp stops the view scrolling past the oldest message. It only walks
+// as far back as the view reaches.
+func (v *chatView) clamp() {
+	if v.offset <= 0 {
+		v.offset = 0
+		return
+	}
+	need := v.offset + v.Height
+	total := 0
+	for i := v.source.len() - 1; i >= 0 && total < need; i-- {
+		total += len(v.lines(i))
+	}
+	if total < need {
+		v.offset = max(0, total-v.Height)
+	}
+}
+
+func (v *chatView) AtBottom() bool {
+	return v.offset == 0
+}
+
+func (v *chatView) PageUp() {
+	v.offset += v.Height
+	v.clamp()
+}
+
+func (v *chatView) PageDown() {
+	v.offset -= v.Height
+	v.clamp()
+}
+
+func (v *chatView) GotoTop() {
+	v.offset = v.source.len() * v.Height
+	v.clamp()
+}
+
+func (v *chatView) GotoBottom() {
+	v.offset = 0
+}
+
+// SetOffset scrolls to a number of lines above the bottom
+func (v *chatView) SetOffset(offset int) {
+	v.offset = offset
+	v.clamp()
+}
+
+// View renders the visible lines. Short histories start at the top like a
+// normal page.
+func (v *chatView) View() string {
+	visible := make([]string, 0, v.Height)
+	skip := v.offset
+	for i := v.source.len() - 1; i >= 0 && len(visible) < v.Height; i-- {
+		lines := v.lines(i)
+		for j := len(lines) - 1; j >= 0 && len(visible) < v.Height; j-- {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			visible = append(visible, lines[j])
+		}
+	}
+
+	// Collected bottom-up; flip into reading order
+	for i, j := 0, len(visible)-1; i < j; i, j = i+1, j-1 {
+		visible[i], visible[j] = visible[j], visible[i]
+	}
+	return lipgloss.NewStyle().Width(v.Width).Height(v.Height).Render(strings.Join(visible, "\n"))
+}
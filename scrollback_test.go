@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkAddMessage adds a message to a full buffer and draws the chat
+// view, as happens for every line in a busy channel. The time per
+// operation should stay flat as the history grows.
+func BenchmarkAddMessage(b *testing.B) {
+	for _, size := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("history=%d", size), func(b *testing.B) {
+			messages := newMessageRing(size)
+			m := &model{
+				messages: messages,
+				viewport: newChatView(messages, 80, 40),
+			}
+			for i := 0; i < size; i++ {
+				m.addMessage(formatUserMessage("alice", fmt.Sprintf("backlog line %d with some text to wrap", i)))
+			}
+			m.viewport.View()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.addMessage(formatUserMessage("bob", fmt.Sprintf("new line %d", i)))
+				m.viewport.View()
+			}
+		})
+	}
+}
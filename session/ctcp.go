@@ -0,0 +1,113 @@
+package session
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	irc "github.com/fluffle/goirc/client"
+)
+
+const (
+	ctcpDelim  = "\x01"
+	ctcpBurst  = 3               // Replies allowed back to back
+	ctcpRefill = 2 * time.Second // Time to earn back one reply
+)
+
+// CTCPClientInfo lists the CTCP commands a session understands
+const CTCPClientInfo = "ACTION CLIENTINFO PING TIME VERSION"
+
+// parseCTCP unwraps a \x01-delimited CTCP payload. goirc already splits
+// well-formed CTCP into its own events; this catches messages from clients
+// that leave off the closing delimiter.
+func parseCTCP(text string) (command, args string, ok bool) {
+	if !strings.HasPrefix(text, ctcpDelim) || len(text) < 2 {
+		return "", "", false
+	}
+	payload := strings.TrimSuffix(text[1:], ctcpDelim)
+	command, args, _ = strings.Cut(payload, " ")
+	if command == "" {
+		return "", "", false
+	}
+	return strings.ToUpper(command), args, true
+}
+
+// goircVersion is the goirc release disableCTCPReplies was written
+// against, as pinned in go.mod
+const goircVersion = "v1.3.3"
+
+// disableCTCPReplies takes goirc's built-in CTCP handler out of a
+// connection. It answers VERSION and PING on its own, ahead of the Ignore
+// hook and the flood limiter, and goirc has no switch to turn it off, so
+// the "ctcp" entry is dropped from the connection's internal handler set.
+// That set is only read once the connection is up. An error means goirc
+// no longer looks the way this expects.
+func disableCTCPReplies(conn *irc.Conn) error {
+	set, err := intHandlers(conn)
+	if err != nil {
+		return err
+	}
+	key := reflect.ValueOf(strings.ToLower(irc.CTCP))
+	if !set.MapIndex(key).IsValid() {
+		return fmt.Errorf("goirc has no internal %q handler", irc.CTCP)
+	}
+	set.SetMapIndex(key, reflect.Value{})
+	return nil
+}
+
+// intHandlers is the map of a connection's internal handlers by event,
+// made writable
+func intHandlers(conn *irc.Conn) (reflect.Value, error) {
+	handlers := reflect.ValueOf(conn).Elem().FieldByName("intHandlers")
+	if !handlers.IsValid() || handlers.Kind() != reflect.Pointer || handlers.IsNil() {
+		return reflect.Value{}, fmt.Errorf("goirc.Conn has no intHandlers set")
+	}
+	set := handlers.Elem().FieldByName("set")
+	if !set.IsValid() || set.Kind() != reflect.Map || set.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}, fmt.Errorf("goirc's handler set is not a map by event")
+	}
+	return reflect.NewAt(set.Type(), unsafe.Pointer(set.UnsafeAddr())).Elem(), nil
+}
+
+// A goirc that cannot be stopped from answering CTCP would answer every
+// query twice, bypassing Ignore and the limiter, so a mismatch stops the
+// program before any session is made
+func init() {
+	if err := disableCTCPReplies(irc.SimpleClient("probe")); err != nil {
+		panic(fmt.Sprintf("session: cannot disable goirc's CTCP replies (written for goirc %s): %v", goircVersion, err))
+	}
+}
+
+// ctcpLimiter is a token bucket guarding automatic CTCP replies against
+// floods
+type ctcpLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newCTCPLimiter() *ctcpLimiter {
+	return &ctcpLimiter{tokens: ctcpBurst, last: time.Now()}
+}
+
+// allow reports whether a reply may be sent now, consuming a token if so
+func (l *ctcpLimiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(ctcpRefill)
+	if l.tokens > ctcpBurst {
+		l.tokens = ctcpBurst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
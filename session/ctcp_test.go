@@ -0,0 +1,100 @@
+package session
+
+import (
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	irc "github.com/fluffle/goirc/client"
+)
+
+// expectQuiet fails if the client sends a line starting with command
+// within a short wait
+func (srv *testServer) expectQuiet(command string) {
+	srv.t.Helper()
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case line, ok := <-srv.lines:
+			if !ok {
+				return
+			}
+			if strings.HasPrefix(line, command+" ") {
+				srv.t.Errorf("client sent %q", line)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// VERSION and PING are answered by the session, through the flood limiter
+func TestCTCPRepliesAreLimited(t *testing.T) {
+	s, srv := connect(t, Config{Version: "test 1.0"})
+
+	srv.send(":alice!a@h PRIVMSG tester :\x01VERSION\x01")
+	for i := range 4 {
+		srv.send(":alice!a@h PRIVMSG tester :\x01PING %d\x01", i)
+	}
+	// The burst covers three replies; the rest are held back
+	for i := range 5 {
+		if e := nextEvent[CTCP](t, s); e.RateLimited != (i >= ctcpBurst) {
+			t.Errorf("request %d = %+v", i, e)
+		}
+	}
+	for _, want := range []string{"\x01VERSION test 1.0\x01", "\x01PING 0\x01", "\x01PING 1\x01"} {
+		if got := srv.expect("NOTICE"); got != "NOTICE alice :"+want {
+			t.Errorf("reply = %q, want %q", got, want)
+		}
+	}
+	srv.expectQuiet("NOTICE")
+}
+
+func TestCTCPVersionUnsetIsNotAnswered(t *testing.T) {
+	s, srv := connect(t, Config{})
+	srv.send(":alice!a@h PRIVMSG tester :\x01VERSION\x01")
+	nextEvent[CTCP](t, s)
+	srv.expectQuiet("NOTICE")
+}
+
+// goirc's own CTCP handler is gone from every session, so only the
+// session's replies are sent
+func TestGoircCTCPHandlerRemoved(t *testing.T) {
+	key := reflect.ValueOf(strings.ToLower(irc.CTCP))
+	set, err := intHandlers(irc.SimpleClient("tester"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !set.MapIndex(key).IsValid() {
+		t.Fatal("a plain goirc client has no CTCP handler to remove")
+	}
+
+	s := New(Config{Nick: "tester"})
+	set, err = intHandlers(s.conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.MapIndex(key).IsValid() {
+		t.Error("goirc's CTCP handler is still installed")
+	}
+}
+
+// disableCTCPReplies reaches into goirc's internals, so moving to another
+// goirc release means checking it again and updating goircVersion
+func TestGoircVersionPinned(t *testing.T) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("no build info")
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/fluffle/goirc" {
+			if dep.Version != goircVersion {
+				t.Errorf("built with goirc %s, disableCTCPReplies was checked against %s", dep.Version, goircVersion)
+			}
+			return
+		}
+	}
+	t.Error("goirc not among the dependencies")
+}
@@ -0,0 +1,132 @@
+package session
+
+import "time"
+
+// Event is something that happened on the connection. Events arrive on
+// Session.Events in the order the server sent them, after the session has
+// updated its own state.
+type Event interface {
+	isEvent()
+}
+
+// User is the sender of a line, nick!ident@host
+type User struct {
+	Nick, Ident, Host string
+}
+
+type (
+	// Connected is sent once registration completes, with the nick the
+	// server gave us
+	Connected struct{ Nick string }
+	// Disconnected is the last event of a session
+	Disconnected struct{}
+	// Error reports a failure the user should see, such as failed SASL
+	Error struct{ Err error }
+	// Authenticated is the server's SASL success message
+	Authenticated struct{ Text string }
+
+	Privmsg struct {
+		From         User
+		Target, Text string
+	}
+	Action struct {
+		From         User
+		Target, Text string
+	}
+	Notice struct {
+		From         User // Servers send notices with only Host set
+		Target, Text string
+	}
+	// CTCP is a request; the session answers the ones it knows itself
+	CTCP struct {
+		From                  User
+		Target, Command, Args string
+		RateLimited           bool // An answer was due but held back by the flood limit
+	}
+	CTCPReply struct {
+		From          User
+		Command, Args string
+	}
+
+	// NickChange and Quit list the channels we share with the user, as
+	// they were before the change
+	NickChange struct {
+		From     User
+		New      string
+		Channels []string
+	}
+	Join struct {
+		From    User
+		Channel string
+	}
+	Part struct {
+		From            User
+		Channel, Reason string
+	}
+	Quit struct {
+		From     User
+		Reason   string
+		Channels []string
+	}
+	Kick struct {
+		From                    User
+		Channel, Target, Reason string
+	}
+	// Mode is a MODE line for a channel or for us; Setter is the server
+	// name when a server set it
+	Mode struct {
+		Setter, Target, Modes string
+		Args                  []string
+	}
+	// UserMode carries our full user modes from RPL_UMODEIS
+	UserMode struct{ Modes string }
+
+	// Topic is a live TOPIC change when Changed is set, and the topic sent
+	// on join otherwise
+	Topic struct {
+		Channel, Topic, SetBy string
+		Changed               bool
+	}
+	TopicWhoTime struct {
+		Channel, SetBy string
+		SetAt          time.Time
+	}
+	// Names is one RPL_NAMREPLY line; EndOfNames closes the list
+	Names struct {
+		Channel string
+		Names   []string
+	}
+	EndOfNames struct{ Channel string }
+	// Members is a copy of a channel's member list, nick to prefix
+	// symbols, sent after each event that changed it. It is nil once we
+	// have left the channel.
+	Members struct {
+		Channel string
+		Members map[string]string
+	}
+	// JoinError is a numeric refusing a join, such as a ban or a full channel
+	JoinError struct{ Channel, Text string }
+)
+
+func (Connected) isEvent()     {}
+func (Disconnected) isEvent()  {}
+func (Error) isEvent()         {}
+func (Authenticated) isEvent() {}
+func (Privmsg) isEvent()       {}
+func (Action) isEvent()        {}
+func (Notice) isEvent()        {}
+func (CTCP) isEvent()          {}
+func (CTCPReply) isEvent()     {}
+func (NickChange) isEvent()    {}
+func (Join) isEvent()          {}
+func (Part) isEvent()          {}
+func (Quit) isEvent()          {}
+func (Kick) isEvent()          {}
+func (Mode) isEvent()          {}
+func (UserMode) isEvent()      {}
+func (Topic) isEvent()         {}
+func (TopicWhoTime) isEvent()  {}
+func (Names) isEvent()         {}
+func (EndOfNames) isEvent()    {}
+func (Members) isEvent()       {}
+func (JoinError) isEvent()     {}
@@ -0,0 +1,360 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	irc "github.com/fluffle/goirc/client"
+)
+
+func lineUser(line *irc.Line) User {
+	return User{Nick: line.Nick, Ident: line.Ident, Host: line.Host}
+}
+
+// register installs the goirc handlers. Each updates the state first and
+// then emits its event, so a reader sees state at least as new as the
+// event it is handling.
+func (s *Session) register() {
+	c := s.conn
+
+	c.HandleFunc(irc.CONNECTED, func(conn *irc.Conn, line *irc.Line) {
+		nick := conn.Me().Nick
+		s.update(func(st *state) { st.nick = nick })
+		s.emit(Connected{Nick: nick})
+	})
+
+	c.HandleFunc(irc.DISCONNECTED, func(conn *irc.Conn, line *irc.Line) {
+		s.update(func(st *state) { st.channels = make(map[string]*channelState) })
+		s.emit(Disconnected{})
+		s.close()
+	})
+
+	c.HandleFunc(irc.NICK, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 1 {
+			return
+		}
+		var channels []string
+		s.update(func(st *state) { channels = st.rename(line.Nick, line.Args[0]) })
+		s.emit(NickChange{From: lineUser(line), New: line.Args[0], Channels: channels})
+		s.emitMembers(channels...)
+	})
+
+	c.HandleFunc(irc.PRIVMSG, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		target, text := line.Args[0], line.Args[1]
+		if command, args, ok := parseCTCP(text); ok {
+			// Unterminated CTCP that goirc passed through as plain text
+			if command == irc.ACTION {
+				s.deliver(Action{From: lineUser(line), Target: target, Text: args})
+			} else {
+				s.handleCTCPRequest(conn, CTCP{From: lineUser(line), Target: target, Command: command, Args: args})
+			}
+			return
+		}
+		s.deliver(Privmsg{From: lineUser(line), Target: target, Text: text})
+	})
+
+	c.HandleFunc(irc.ACTION, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		s.deliver(Action{From: lineUser(line), Target: line.Args[0], Text: line.Args[1]})
+	})
+
+	// goirc puts the CTCP command first: [command, target, args]
+	c.HandleFunc(irc.CTCP, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		s.handleCTCPRequest(conn, CTCP{From: lineUser(line), Target: line.Args[1], Command: line.Args[0], Args: ctcpArgs(line)})
+	})
+
+	c.HandleFunc(irc.CTCPREPLY, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		s.deliver(CTCPReply{From: lineUser(line), Command: line.Args[0], Args: ctcpArgs(line)})
+	})
+
+	c.HandleFunc(irc.NOTICE, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		s.deliver(Notice{From: lineUser(line), Target: line.Args[0], Text: line.Args[1]})
+	})
+
+	c.HandleFunc(irc.JOIN, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 1 {
+			return
+		}
+		s.update(func(st *state) { st.join(line.Nick, line.Args[0]) })
+		s.emit(Join{From: lineUser(line), Channel: line.Args[0]})
+		s.emitMembers(line.Args[0])
+	})
+
+	c.HandleFunc(irc.PART, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 1 {
+			return
+		}
+		reason := ""
+		if len(line.Args) > 1 {
+			reason = line.Args[1]
+		}
+		s.update(func(st *state) { st.part(line.Nick, line.Args[0]) })
+		s.emit(Part{From: lineUser(line), Channel: line.Args[0], Reason: reason})
+		s.emitMembers(line.Args[0])
+	})
+
+	c.HandleFunc(irc.QUIT, func(conn *irc.Conn, line *irc.Line) {
+		reason := ""
+		if len(line.Args) > 0 {
+			reason = line.Args[0]
+		}
+		var channels []string
+		s.update(func(st *state) { channels = st.quit(line.Nick) })
+		s.emit(Quit{From: lineUser(line), Reason: reason, Channels: channels})
+		s.emitMembers(channels...)
+	})
+
+	c.HandleFunc(irc.KICK, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		reason := ""
+		if len(line.Args) > 2 {
+			reason = line.Args[2]
+		}
+		s.update(func(st *state) { st.part(line.Args[1], line.Args[0]) })
+		s.emit(Kick{From: lineUser(line), Channel: line.Args[0], Target: line.Args[1], Reason: reason})
+		s.emitMembers(line.Args[0])
+	})
+
+	c.HandleFunc(irc.MODE, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		setter := line.Nick
+		if setter == "" {
+			setter = line.Host
+		}
+		var inChannel bool
+		s.update(func(st *state) {
+			st.mode(line.Args[0], line.Args[1], line.Args[2:])
+			inChannel = st.channel(line.Args[0]) != nil
+		})
+		s.emit(Mode{Setter: setter, Target: line.Args[0], Modes: line.Args[1], Args: line.Args[2:]})
+		if inChannel {
+			s.emitMembers(line.Args[0])
+		}
+	})
+
+	c.HandleFunc(irc.TOPIC, func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 2 {
+			return
+		}
+		s.setTopic(line.Args[0], line.Args[1], line.Nick, line.Time)
+		s.emit(Topic{Channel: line.Args[0], Topic: line.Args[1], SetBy: line.Nick, Changed: true})
+	})
+
+	// RPL_NOTOPIC: <me> <channel> :No topic is set
+	c.HandleFunc("331", func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) > 1 {
+			s.setTopic(line.Args[1], "", "", time.Time{})
+			s.emit(Topic{Channel: line.Args[1]})
+		}
+	})
+
+	// RPL_TOPIC: <me> <channel> :<topic>
+	c.HandleFunc("332", func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) > 2 {
+			s.setTopic(line.Args[1], line.Args[2], "", time.Time{})
+			s.emit(Topic{Channel: line.Args[1], Topic: line.Args[2]})
+		}
+	})
+
+	// RPL_TOPICWHOTIME: <me> <channel> <setter> <unix time>
+	c.HandleFunc("333", func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) < 4 {
+			return
+		}
+		setBy := line.Args[2]
+		if nick, _, ok := strings.Cut(setBy, "!"); ok {
+			setBy = nick
+		}
+		var setAt time.Time
+		if seconds, err := strconv.ParseInt(line.Args[3], 10, 64); err == nil {
+			setAt = time.Unix(seconds, 0)
+		}
+		s.update(func(st *state) {
+			if c := st.channel(line.Args[1]); c != nil {
+				c.topicSetBy, c.topicSetAt = setBy, setAt
+			}
+		})
+		s.emit(TopicWhoTime{Channel: line.Args[1], SetBy: setBy, SetAt: setAt})
+	})
+
+	// RPL_ISUPPORT: membership prefixes and channel mode types
+	c.HandleFunc("005", func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) > 2 {
+			tokens := line.Args[1 : len(line.Args)-1]
+			s.update(func(st *state) { st.modes.ApplyISupport(tokens) })
+		}
+	})
+
+	// RPL_NAMREPLY: <me> <type> <channel> :<names>
+	c.HandleFunc("353", func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) > 3 {
+			names := strings.Fields(line.Args[3])
+			s.update(func(st *state) { st.names(line.Args[2], names) })
+			s.emit(Names{Channel: line.Args[2], Names: names})
+		}
+	})
+
+	// RPL_ENDOFNAMES: <me> <channel> :End of /NAMES list
+	c.HandleFunc("366", func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) > 1 {
+			s.update(func(st *state) { st.endOfNames(line.Args[1]) })
+			s.emit(EndOfNames{Channel: line.Args[1]})
+			s.emitMembers(line.Args[1])
+		}
+	})
+
+	// RPL_UMODEIS: <me> <modes>
+	c.HandleFunc("221", func(conn *irc.Conn, line *irc.Line) {
+		if len(line.Args) > 1 {
+			modes := strings.TrimPrefix(line.Args[1], "+")
+			s.update(func(st *state) { st.userModes = modes })
+			s.emit(UserMode{Modes: modes})
+		}
+	})
+
+	// Reasons a join was refused: <me> <channel> :<reason>
+	for _, numeric := range []string{"403", "405", "471", "473", "474", "475", "477"} {
+		c.HandleFunc(numeric, func(conn *irc.Conn, line *irc.Line) {
+			if len(line.Args) >= 2 {
+				s.emit(JoinError{Channel: line.Args[1], Text: line.Text()})
+			}
+		})
+	}
+
+	if s.cfg.SASL != nil {
+		s.registerSASL()
+	}
+}
+
+// emitMembers follows an event with copies of the member lists it
+// changed, for readers that keep their own
+func (s *Session) emitMembers(channels ...string) {
+	for _, channel := range channels {
+		s.emit(Members{Channel: channel, Members: s.Members(channel)})
+	}
+}
+
+// setTopic records a channel's topic; RPL_TOPICWHOTIME fills in who set
+// it when the server sends the topic on join
+func (s *Session) setTopic(channel, topic, setBy string, setAt time.Time) {
+	s.update(func(st *state) {
+		if c := st.channel(channel); c != nil {
+			c.topic, c.topicSetBy, c.topicSetAt = topic, setBy, setAt
+		}
+	})
+}
+
+// registerSASL reports the outcome of SASL authentication and finishes
+// SCRAM exchanges that goirc cannot complete on its own
+func (s *Session) registerSASL() {
+	fail := func(format string, args ...any) {
+		s.emit(Error{Err: fmt.Errorf(format, args...)})
+	}
+
+	// goirc has handled the challenge by now. It sends nothing when the
+	// SCRAM client fails, which would leave the server waiting, so the
+	// exchange is aborted here.
+	s.conn.HandleFunc(irc.AUTHENTICATE, func(conn *irc.Conn, line *irc.Line) {
+		scram, ok := conn.Config().Sasl.(*scramClient)
+		switch {
+		case !ok:
+		case scram.verified:
+			scram.verified = false
+			conn.Authenticate("+")
+		case scram.err != nil && !scram.aborted:
+			scram.aborted = true
+			conn.Authenticate("*")
+			fail("SASL authentication failed: %v", scram.err)
+		}
+	})
+
+	s.conn.HandleFunc("900", func(conn *irc.Conn, line *irc.Line) {
+		s.emit(Authenticated{Text: line.Text()})
+	})
+
+	s.conn.HandleFunc("902", func(conn *irc.Conn, line *irc.Line) {
+		fail("SASL authentication unavailable: %s", line.Text())
+	})
+
+	s.conn.HandleFunc("904", func(conn *irc.Conn, line *irc.Line) {
+		fail("SASL authentication failed: %s", line.Text())
+	})
+
+	s.conn.HandleFunc("905", func(conn *irc.Conn, line *irc.Line) {
+		fail("SASL authentication failed: message too long")
+	})
+
+	// goirc ends capability negotiation after 904 but not 906
+	s.conn.HandleFunc("906", func(conn *irc.Conn, line *irc.Line) {
+		conn.Cap(irc.CAP_END)
+		if scram, ok := conn.Config().Sasl.(*scramClient); ok && scram.aborted {
+			return // Already reported with its reason
+		}
+		fail("SASL authentication aborted")
+	})
+
+	s.conn.HandleFunc("908", func(conn *irc.Conn, line *irc.Line) {
+		mechanisms := ""
+		if len(line.Args) > 1 {
+			mechanisms = line.Args[1]
+		}
+		fail("SASL mechanism rejected by server (available: %s)", mechanisms)
+	})
+}
+
+// handleCTCPRequest answers CTCP queries and delivers them. Requests from
+// ignored users get neither, and replies stop when the limiter runs dry.
+func (s *Session) handleCTCPRequest(conn *irc.Conn, request CTCP) {
+	if s.ignored(request) {
+		return
+	}
+
+	var reply string
+	switch request.Command {
+	case irc.VERSION:
+		reply = s.cfg.Version
+	case irc.PING:
+		reply = request.Args
+	case "TIME":
+		reply = time.Now().Format(time.RFC1123Z)
+	case "CLIENTINFO":
+		reply = CTCPClientInfo
+	}
+	if reply != "" {
+		if s.limiter.allow() {
+			conn.CtcpReply(request.From.Nick, request.Command, reply)
+		} else {
+			request.RateLimited = true
+		}
+	}
+	s.emit(request)
+}
+
+// ctcpArgs extracts CTCP arguments from a goirc CTCP/CTCPREPLY line. With no
+// arguments goirc leaves the raw \x01-wrapped text in their place.
+func ctcpArgs(line *irc.Line) string {
+	if len(line.Args) < 3 || strings.HasPrefix(line.Args[2], ctcpDelim) {
+		return ""
+	}
+	return line.Args[2]
+}
@@ -0,0 +1,158 @@
+package session
+
+import "strings"
+
+// Default prefix and channel mode tables, replaced by ISUPPORT when the server sends them
+const (
+	defaultPrefixModes   = "qaohv"
+	defaultPrefixSymbols = "~&@%+"
+)
+
+var defaultChanModes = [4]string{"beI", "k", "l", "imnpst"}
+
+// Modes maps membership prefix modes to their symbols, ordered by rank
+type Modes struct {
+	PrefixModes   string    // e.g. "qaohv"
+	PrefixSymbols string    // e.g. "~&@%+"
+	ChanModes     [4]string // CHANMODES groups A,B,C,D
+}
+
+// DefaultModes is the table used until the server sends its own
+func DefaultModes() Modes {
+	return Modes{
+		PrefixModes:   defaultPrefixModes,
+		PrefixSymbols: defaultPrefixSymbols,
+		ChanModes:     defaultChanModes,
+	}
+}
+
+// ApplyISupport updates the mode tables from RPL_ISUPPORT tokens
+func (mm *Modes) ApplyISupport(tokens []string) {
+	for _, token := range tokens {
+		key, value, _ := strings.Cut(token, "=")
+		switch key {
+		case "PREFIX":
+			// PREFIX=(qaohv)~&@%+
+			if strings.HasPrefix(value, "(") {
+				if modes, symbols, ok := strings.Cut(value[1:], ")"); ok && len(modes) == len(symbols) {
+					mm.PrefixModes = modes
+					mm.PrefixSymbols = symbols
+				}
+			}
+		case "CHANMODES":
+			groups := strings.Split(value, ",")
+			for i := 0; i < len(groups) && i < len(mm.ChanModes); i++ {
+				mm.ChanModes[i] = groups[i]
+			}
+		}
+	}
+}
+
+// SplitPrefix separates leading membership symbols from a NAMES entry
+func (mm Modes) SplitPrefix(name string) (prefix, nick string) {
+	i := 0
+	for i < len(name) && strings.IndexByte(mm.PrefixSymbols, name[i]) >= 0 {
+		i++
+	}
+	return name[:i], name[i:]
+}
+
+// Rank returns the position of the member's highest prefix; lower is higher
+func (mm Modes) Rank(prefix string) int {
+	best := len(mm.PrefixSymbols)
+	for i := 0; i < len(prefix); i++ {
+		if idx := strings.IndexByte(mm.PrefixSymbols, prefix[i]); idx >= 0 && idx < best {
+			best = idx
+		}
+	}
+	return best
+}
+
+// WithSymbol adds or removes a prefix symbol, keeping the result in rank order
+func (mm Modes) WithSymbol(prefix string, symbol byte, add bool) string {
+	var b strings.Builder
+	for i := 0; i < len(mm.PrefixSymbols); i++ {
+		s := mm.PrefixSymbols[i]
+		has := strings.IndexByte(prefix, s) >= 0
+		if s == symbol {
+			has = add
+		}
+		if has {
+			b.WriteByte(s)
+		}
+	}
+	return b.String()
+}
+
+// ApplyChange returns a member's prefix after a channel mode change, and
+// whether the change was to a membership mode at all
+func (mm Modes) ApplyChange(prefix string, change ModeChange) (string, bool) {
+	idx := strings.IndexByte(mm.PrefixModes, change.Mode)
+	if idx < 0 || change.Arg == "" {
+		return prefix, false
+	}
+	return mm.WithSymbol(prefix, mm.PrefixSymbols[idx], change.Adding), true
+}
+
+// takesArg reports whether a channel mode consumes a parameter
+func (mm Modes) takesArg(mode byte, adding bool) bool {
+	switch {
+	case strings.IndexByte(mm.PrefixModes, mode) >= 0:
+		return true
+	case strings.IndexByte(mm.ChanModes[0], mode) >= 0, strings.IndexByte(mm.ChanModes[1], mode) >= 0:
+		return true
+	case strings.IndexByte(mm.ChanModes[2], mode) >= 0:
+		return adding
+	}
+	return false
+}
+
+// ModeChange is a single parsed channel mode change
+type ModeChange struct {
+	Adding bool
+	Mode   byte
+	Arg    string
+}
+
+// ParseChanges splits a MODE line into individual changes
+func (mm Modes) ParseChanges(modes string, args []string) []ModeChange {
+	var changes []ModeChange
+	adding := true
+	for i := 0; i < len(modes); i++ {
+		switch modes[i] {
+		case '+':
+			adding = true
+		case '-':
+			adding = false
+		default:
+			change := ModeChange{Adding: adding, Mode: modes[i]}
+			if mm.takesArg(modes[i], adding) && len(args) > 0 {
+				change.Arg = args[0]
+				args = args[1:]
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// ApplyUserModes folds a user MODE change such as "+i-w" into the current modes
+func ApplyUserModes(current, modes string) string {
+	adding := true
+	for i := 0; i < len(modes); i++ {
+		switch mode := modes[i]; mode {
+		case '+':
+			adding = true
+		case '-':
+			adding = false
+		default:
+			has := strings.IndexByte(current, mode) >= 0
+			if adding && !has {
+				current += string(mode)
+			} else if !adding && has {
+				current = strings.ReplaceAll(current, string(mode), "")
+			}
+		}
+	}
+	return current
+}
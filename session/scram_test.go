@@ -0,0 +1,70 @@
+package session
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// authenticate reads the payload of the client's next AUTHENTICATE line
+func (srv *testServer) authenticate() string {
+	srv.t.Helper()
+	line := srv.expect("AUTHENTICATE")
+	payload := strings.TrimPrefix(line, "AUTHENTICATE ")
+	if payload == "+" || payload == "*" {
+		return payload
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		srv.t.Fatalf("client sent %q: %v", line, err)
+	}
+	return string(data)
+}
+
+func (srv *testServer) challenge(data string) {
+	srv.send("AUTHENTICATE %s", base64.StdEncoding.EncodeToString([]byte(data)))
+}
+
+// A server that cannot prove it knows the password is refused: the client
+// aborts the exchange and says why
+func TestSCRAMBadServerSignature(t *testing.T) {
+	client, err := NewSCRAMClient("tester", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, srv := dial(t, Config{SASL: client})
+
+	srv.expect("CAP LS")
+	srv.send(":irc.test CAP * LS :sasl")
+	srv.expect("CAP REQ")
+	srv.send(":irc.test CAP tester ACK :sasl")
+	if got := srv.expect("AUTHENTICATE"); got != "AUTHENTICATE SCRAM-SHA-256" {
+		t.Fatalf("client sent %q", got)
+	}
+	srv.send("AUTHENTICATE +")
+
+	clientFirst := srv.authenticate()
+	_, nonce, ok := strings.Cut(clientFirst, ",r=")
+	if !ok {
+		t.Fatalf("client-first-message = %q", clientFirst)
+	}
+	salt := base64.StdEncoding.EncodeToString([]byte("salt"))
+	srv.challenge("r=" + nonce + "server,s=" + salt + ",i=4096")
+	if got := srv.authenticate(); !strings.Contains(got, ",p=") {
+		t.Fatalf("client-final-message = %q", got)
+	}
+	srv.challenge("v=" + base64.StdEncoding.EncodeToString([]byte("not the signature")))
+
+	if got := srv.authenticate(); got != "*" {
+		t.Errorf("client answered a bad signature with %q, want an abort", got)
+	}
+	if e := nextEvent[Error](t, s); !strings.Contains(e.Err.Error(), "invalid server signature") {
+		t.Errorf("error = %v", e.Err)
+	}
+
+	// Registration goes on once the server confirms the abort
+	srv.send(":irc.test 906 tester :SASL authentication aborted")
+	srv.expect("CAP END")
+	srv.send(":irc.test 001 tester :Welcome tester")
+	nextEvent[Connected](t, s)
+}
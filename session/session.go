@@ -0,0 +1,289 @@
+// Package session is the IRC core of the client. A Session owns one
+// connection, keeps track of our nick, channels, members and topics, and
+// reports what happens as a stream of events. The TUI is one user of it;
+// bots and tests can drive it the same way without rendering anything.
+package session
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+	"sync"
+	"time"
+
+	sasl "github.com/emersion/go-sasl"
+	irc "github.com/fluffle/goirc/client"
+)
+
+// eventBuffer is how many events may wait for the reader before the
+// connection stops reading from the server
+const eventBuffer = 256
+
+// Config describes the connection a session makes
+type Config struct {
+	Server       string // host:port
+	Nick         string
+	Ident        string // Defaults to goirc's own when empty
+	RealName     string
+	Password     string // Server password
+	QuitMessage  string
+	TLS          bool
+	TLSConfig    *tls.Config    // Defaults to verifying the server's host name
+	RootCAs      *x509.CertPool // Trusted in place of the system roots when set
+	SASL         sasl.Client    // Nil to skip SASL
+	Version      string         // Reply to CTCP VERSION; none is sent when empty
+	Capabilities []string       // Requested on top of goirc's own
+
+	// Ignore is asked about each message, notice and CTCP before it is
+	// delivered. Events it returns true for are dropped, and CTCP requests
+	// among them go unanswered, as every CTCP reply comes from the session.
+	// It runs on the connection's goroutine.
+	Ignore func(Event) bool
+}
+
+// Session is one IRC connection. Its methods are safe to call from any
+// goroutine.
+type Session struct {
+	cfg     Config
+	conn    *irc.Conn
+	limiter *ctcpLimiter
+
+	mu    sync.Mutex // Guards state
+	state state
+
+	events chan Event
+	sendMu sync.Mutex // Guards closed and sending on events
+	closed bool
+}
+
+// New prepares a session; Connect dials the server
+func New(cfg Config) *Session {
+	ircCfg := irc.NewConfig(cfg.Nick)
+	ircCfg.Server = cfg.Server
+	ircCfg.SSL = cfg.TLS
+	if cfg.TLS {
+		ircCfg.SSLConfig = cfg.TLSConfig
+		if ircCfg.SSLConfig == nil {
+			host, _, _ := strings.Cut(cfg.Server, ":")
+			ircCfg.SSLConfig = &tls.Config{ServerName: host}
+		}
+		if cfg.RootCAs != nil {
+			ircCfg.SSLConfig = ircCfg.SSLConfig.Clone()
+			ircCfg.SSLConfig.RootCAs = cfg.RootCAs
+		}
+	}
+	if cfg.Ident != "" {
+		ircCfg.Me.Ident = cfg.Ident
+	}
+	if cfg.RealName != "" {
+		ircCfg.Me.Name = cfg.RealName
+	}
+	ircCfg.Pass = cfg.Password
+	if cfg.QuitMessage != "" {
+		ircCfg.QuitMessage = cfg.QuitMessage
+	}
+	ircCfg.Sasl = cfg.SASL
+	ircCfg.NewNick = func(n string) string { return n + "_" }
+	// Every CTCP reply is the session's own, see disableCTCPReplies
+	ircCfg.Version = ""
+	ircCfg.EnableCapabilityNegotiation = true
+	ircCfg.Capabilites = append(ircCfg.Capabilites, cfg.Capabilities...)
+
+	conn := irc.Client(ircCfg)
+	if err := disableCTCPReplies(conn); err != nil {
+		panic(err) // Checked in init
+	}
+	s := &Session{
+		cfg:     cfg,
+		conn:    conn,
+		limiter: newCTCPLimiter(),
+		state: state{
+			nick:     cfg.Nick,
+			modes:    DefaultModes(),
+			channels: make(map[string]*channelState),
+		},
+		events: make(chan Event, eventBuffer),
+	}
+	s.register()
+	return s
+}
+
+// Connect dials the server and starts registration. Connected follows on
+// the event stream once the server accepts us. If the dial fails the
+// session is over: the event stream is closed and nothing is emitted.
+func (s *Session) Connect() error {
+	if err := s.conn.Connect(); err != nil {
+		s.close()
+		return err
+	}
+	return nil
+}
+
+// Events is the stream of what happens on the connection. It is closed
+// after Disconnected or a failed Connect, and must be read for the
+// connection to make progress.
+func (s *Session) Events() <-chan Event {
+	return s.events
+}
+
+// Join joins a channel, with an optional key
+func (s *Session) Join(channel string, key ...string) { s.conn.Join(channel, key...) }
+
+// Part leaves a channel, with an optional reason
+func (s *Session) Part(channel string, reason ...string) { s.conn.Part(channel, reason...) }
+
+// Privmsg sends a message to a channel or nick
+func (s *Session) Privmsg(target, text string) { s.conn.Privmsg(target, text) }
+
+// Action sends a CTCP ACTION, as /me does
+func (s *Session) Action(target, text string) { s.conn.Action(target, text) }
+
+// Notice sends a notice to a channel or nick
+func (s *Session) Notice(target, text string) { s.conn.Notice(target, text) }
+
+// Ctcp sends a CTCP request
+func (s *Session) Ctcp(target, command string, args ...string) { s.conn.Ctcp(target, command, args...) }
+
+// SetTopic changes the topic of a channel
+func (s *Session) SetTopic(channel, topic string) { s.conn.Topic(channel, topic) }
+
+// Nick asks the server for a new nick; NickChange follows if it agrees
+func (s *Session) Nick(nick string) { s.conn.Nick(nick) }
+
+// Quit leaves the server, with an optional reason. Disconnected follows
+// once the server closes the connection.
+func (s *Session) Quit(reason ...string) { s.conn.Quit(reason...) }
+
+// Close drops the connection without a QUIT
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// Connected reports whether the connection is up
+func (s *Session) Connected() bool {
+	return s.conn.Connected()
+}
+
+// Me is our current nick
+func (s *Session) Me() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.nick
+}
+
+// UserModes are our user modes without the leading +, e.g. "iZ"
+func (s *Session) UserModes() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.userModes
+}
+
+// Modes is the server's membership prefix and channel mode table
+func (s *Session) Modes() Modes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.modes
+}
+
+// Channels lists the channels we are in
+func (s *Session) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.sortedChannels()
+}
+
+// Members maps the nicks in a channel to their prefix symbols
+func (s *Session) Members(channel string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.state.channel(channel)
+	if c == nil {
+		return nil
+	}
+	members := make(map[string]string, len(c.members))
+	for nick, prefix := range c.members {
+		members[nick] = prefix
+	}
+	return members
+}
+
+// Topic is the topic of a channel we are in
+func (s *Session) Topic(channel string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.state.channel(channel); c != nil {
+		return c.topic
+	}
+	return ""
+}
+
+// TopicSetBy is who set the topic of a channel and when, if known
+func (s *Session) TopicSetBy(channel string) (string, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.state.channel(channel); c != nil {
+		return c.topicSetBy, c.topicSetAt
+	}
+	return "", time.Time{}
+}
+
+// update changes the state under the lock
+func (s *Session) update(change func(st *state)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(&s.state)
+}
+
+func (s *Session) isMe(nick string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.isMe(nick)
+}
+
+// emit delivers an event, waiting for the reader if the stream is full.
+// Nothing is sent once the stream is closed.
+func (s *Session) emit(event Event) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if !s.closed {
+		s.events <- event
+	}
+}
+
+// ignored asks the Ignore hook about a message, notice or CTCP; our own
+// are never ignored
+func (s *Session) ignored(event Event) bool {
+	if s.cfg.Ignore == nil {
+		return false
+	}
+	var from User
+	switch e := event.(type) {
+	case Privmsg:
+		from = e.From
+	case Action:
+		from = e.From
+	case Notice:
+		from = e.From
+	case CTCP:
+		from = e.From
+	case CTCPReply:
+		from = e.From
+	}
+	return !s.isMe(from.Nick) && s.cfg.Ignore(event)
+}
+
+// deliver emits a message-like event unless the Ignore hook drops it
+func (s *Session) deliver(event Event) {
+	if !s.ignored(event) {
+		s.emit(event)
+	}
+}
+
+func (s *Session) close() {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
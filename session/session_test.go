@@ -0,0 +1,355 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTimeout bounds every wait on the test server or the event stream
+const testTimeout = 5 * time.Second
+
+// testServer is the server end of one session, played line by line
+type testServer struct {
+	t     *testing.T
+	conn  net.Conn
+	lines chan string // Lines from the client
+}
+
+// connect starts a session as "tester" against a local server and
+// completes registration, returning once Connected has been read
+func connect(t *testing.T, cfg Config) (*Session, *testServer) {
+	t.Helper()
+	s, srv := dial(t, cfg)
+	srv.expect("USER")
+	srv.send(":irc.test 001 tester :Welcome tester")
+	srv.send(":irc.test 005 tester PREFIX=(ov)@+ CHANMODES=beI,k,l,imnpst :are supported by this server")
+	if e := nextEvent[Connected](t, s); e.Nick != "tester" {
+		t.Fatalf("connected as %q", e.Nick)
+	}
+	return s, srv
+}
+
+// dial starts a session as "tester" and accepts its connection
+func dial(t *testing.T, cfg Config) (*Session, *testServer) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	cfg.Server = ln.Addr().String()
+	if cfg.Nick == "" {
+		cfg.Nick = "tester"
+	}
+	s := New(cfg)
+	s.conn.Config().Flood = true // The server is local; don't pace the client
+	if err := s.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &testServer{t: t, conn: conn, lines: make(chan string, 64)}
+	t.Cleanup(func() { conn.Close() })
+	go srv.read()
+	return s, srv
+}
+
+func (srv *testServer) read() {
+	defer close(srv.lines)
+	r := bufio.NewReader(srv.conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		srv.lines <- strings.TrimRight(line, "\r\n")
+	}
+}
+
+func (srv *testServer) send(format string, args ...any) {
+	srv.t.Helper()
+	if _, err := fmt.Fprintf(srv.conn, format+"\r\n", args...); err != nil {
+		srv.t.Fatalf("sending to client: %v", err)
+	}
+}
+
+// expect skips client lines until one starting with command arrives
+func (srv *testServer) expect(command string) string {
+	srv.t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case line, ok := <-srv.lines:
+			if !ok {
+				srv.t.Fatalf("client hung up while waiting for %s", command)
+			}
+			if strings.HasPrefix(line, command+" ") || line == command {
+				return line
+			}
+		case <-timeout:
+			srv.t.Fatalf("timed out waiting for %s from the client", command)
+		}
+	}
+}
+
+// nextEvent reads the next event, failing unless it is a T
+func nextEvent[T Event](t *testing.T, s *Session) T {
+	t.Helper()
+	var want T
+	select {
+	case event, ok := <-s.Events():
+		if !ok {
+			t.Fatal("event stream closed")
+		}
+		e, ok := event.(T)
+		if !ok {
+			t.Fatalf("got %#v, want a %T", event, want)
+		}
+		return e
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for a %T", want)
+	}
+	return want
+}
+
+// joinTest joins #test with alice, @bob and +carol
+func joinTest(t *testing.T, s *Session, srv *testServer) {
+	t.Helper()
+	srv.send(":tester!u@h JOIN #test")
+	srv.send(":irc.test 353 tester = #test :tester alice @bob +carol")
+	srv.send(":irc.test 366 tester #test :End of /NAMES list")
+	nextEvent[Join](t, s)
+	expectMembers(t, s, "#test", map[string]string{"tester": ""})
+	nextEvent[Names](t, s)
+	nextEvent[EndOfNames](t, s)
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": "@", "carol": "+"})
+}
+
+func checkMembers(t *testing.T, s *Session, channel string, want map[string]string) {
+	t.Helper()
+	if got := s.Members(channel); !reflect.DeepEqual(got, want) {
+		t.Errorf("members of %s = %v, want %v", channel, got, want)
+	}
+}
+
+// expectMembers reads the copy of a member list that follows a change to it
+func expectMembers(t *testing.T, s *Session, channel string, want map[string]string) {
+	t.Helper()
+	if e := nextEvent[Members](t, s); e.Channel != channel || !reflect.DeepEqual(e.Members, want) {
+		t.Errorf("members of %s = %v, want %v of %s", e.Channel, e.Members, want, channel)
+	}
+}
+
+func TestJoinAndNames(t *testing.T) {
+	s, srv := connect(t, Config{})
+	joinTest(t, s, srv)
+
+	if got := s.Channels(); !reflect.DeepEqual(got, []string{"#test"}) {
+		t.Errorf("channels = %v", got)
+	}
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": "@", "carol": "+"})
+
+	// A later NAMES reply replaces the list rather than adding to it
+	srv.send(":irc.test 353 tester = #test :tester @dave")
+	srv.send(":irc.test 366 tester #test :End of /NAMES list")
+	nextEvent[Names](t, s)
+	nextEvent[EndOfNames](t, s)
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "dave": "@"})
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "dave": "@"})
+}
+
+func TestMembershipChanges(t *testing.T) {
+	s, srv := connect(t, Config{})
+	joinTest(t, s, srv)
+
+	srv.send(":dave!d@h JOIN #TEST")
+	nextEvent[Join](t, s)
+	expectMembers(t, s, "#TEST", map[string]string{"tester": "", "alice": "", "bob": "@", "carol": "+", "dave": ""})
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": "@", "carol": "+", "dave": ""})
+
+	srv.send(":dave!d@h PART #test :bye")
+	if e := nextEvent[Part](t, s); e.From.Nick != "dave" || e.Reason != "bye" {
+		t.Errorf("part = %+v", e)
+	}
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": "@", "carol": "+"})
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": "@", "carol": "+"})
+
+	srv.send(":bob!b@h KICK #test carol :behave")
+	if e := nextEvent[Kick](t, s); e.Target != "carol" || e.Reason != "behave" {
+		t.Errorf("kick = %+v", e)
+	}
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": "@"})
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": "@"})
+
+	srv.send(":bob!b@h NICK robert")
+	e := nextEvent[NickChange](t, s)
+	if e.New != "robert" || !reflect.DeepEqual(e.Channels, []string{"#test"}) {
+		t.Errorf("nick change = %+v", e)
+	}
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "robert": "@"})
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "robert": "@"})
+
+	srv.send(":robert!b@h MODE #test -o+v robert alice")
+	nextEvent[Mode](t, s)
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "alice": "+", "robert": ""})
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "alice": "+", "robert": ""})
+
+	srv.send(":alice!a@h QUIT :gone")
+	if e := nextEvent[Quit](t, s); e.Reason != "gone" || !reflect.DeepEqual(e.Channels, []string{"#test"}) {
+		t.Errorf("quit = %+v", e)
+	}
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "robert": ""})
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "robert": ""})
+
+	// Someone we share no channel with
+	srv.send(":zed!z@h QUIT :gone")
+	if e := nextEvent[Quit](t, s); len(e.Channels) != 0 {
+		t.Errorf("quit lists channels %v for a stranger", e.Channels)
+	}
+}
+
+func TestOwnChanges(t *testing.T) {
+	s, srv := connect(t, Config{})
+	joinTest(t, s, srv)
+	srv.send(":tester!u@h JOIN #other")
+	nextEvent[Join](t, s)
+	expectMembers(t, s, "#other", map[string]string{"tester": ""})
+
+	srv.send(":tester!u@h NICK tested")
+	if e := nextEvent[NickChange](t, s); !reflect.DeepEqual(e.Channels, []string{"#other", "#test"}) {
+		t.Errorf("nick change lists %v", e.Channels)
+	}
+	expectMembers(t, s, "#other", map[string]string{"tested": ""})
+	expectMembers(t, s, "#test", map[string]string{"tested": "", "alice": "", "bob": "@", "carol": "+"})
+	if got := s.Me(); got != "tested" {
+		t.Errorf("nick = %q, want tested", got)
+	}
+
+	// Leaving a channel empties its list
+	srv.send(":tested!u@h PART #other")
+	nextEvent[Part](t, s)
+	expectMembers(t, s, "#other", nil)
+	srv.send(":bob!b@h KICK #test tested :out")
+	nextEvent[Kick](t, s)
+	expectMembers(t, s, "#test", nil)
+	if got := s.Channels(); len(got) != 0 {
+		t.Errorf("still in %v", got)
+	}
+	if got := s.Members("#test"); got != nil {
+		t.Errorf("members of a channel we left = %v", got)
+	}
+
+	srv.send(":tested MODE tested :+iZ")
+	nextEvent[Mode](t, s)
+	if got := s.UserModes(); got != "iZ" {
+		t.Errorf("user modes = %q, want iZ", got)
+	}
+}
+
+func TestTopic(t *testing.T) {
+	s, srv := connect(t, Config{})
+	srv.send(":tester!u@h JOIN #test")
+	srv.send(":irc.test 332 tester #test :Welcome")
+	srv.send(":irc.test 333 tester #test alice!a@h 1700000000")
+	nextEvent[Join](t, s)
+	nextEvent[Members](t, s)
+	if e := nextEvent[Topic](t, s); e.Topic != "Welcome" || e.Changed {
+		t.Errorf("topic = %+v", e)
+	}
+	nextEvent[TopicWhoTime](t, s)
+	if got := s.Topic("#test"); got != "Welcome" {
+		t.Errorf("topic = %q", got)
+	}
+	if by, at := s.TopicSetBy("#test"); by != "alice" || !at.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("topic set by %q at %v", by, at)
+	}
+
+	srv.send(":bob!b@h TOPIC #test :New topic")
+	if e := nextEvent[Topic](t, s); e.SetBy != "bob" || !e.Changed {
+		t.Errorf("topic change = %+v", e)
+	}
+	if by, _ := s.TopicSetBy("#test"); s.Topic("#test") != "New topic" || by != "bob" {
+		t.Errorf("topic = %q set by %q", s.Topic("#test"), by)
+	}
+}
+
+// Events come out in the order the server sent them, each after the state
+// it describes
+func TestEventOrder(t *testing.T) {
+	s, srv := connect(t, Config{})
+	srv.send(":tester!u@h JOIN #test")
+	srv.send(":irc.test 353 tester = #test :tester alice")
+	srv.send(":irc.test 366 tester #test :End of /NAMES list")
+	srv.send(":alice!a@h PRIVMSG #test :one")
+	srv.send(":bob!b@h JOIN #test")
+	srv.send(":bob!b@h PRIVMSG #test :two")
+	srv.send(":alice!a@h PART #test")
+	srv.send(":bob!b@h PRIVMSG #test :three")
+
+	nextEvent[Join](t, s)
+	nextEvent[Members](t, s)
+	nextEvent[Names](t, s)
+	nextEvent[EndOfNames](t, s)
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "alice": ""})
+	if e := nextEvent[Privmsg](t, s); e.Text != "one" {
+		t.Errorf("first message = %q", e.Text)
+	}
+	nextEvent[Join](t, s)
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "alice": "", "bob": ""})
+	if e := nextEvent[Privmsg](t, s); e.Text != "two" {
+		t.Errorf("second message = %q", e.Text)
+	}
+	nextEvent[Part](t, s)
+	expectMembers(t, s, "#test", map[string]string{"tester": "", "bob": ""})
+	if e := nextEvent[Privmsg](t, s); e.Text != "three" {
+		t.Errorf("third message = %q", e.Text)
+	}
+	// The stream is read after the server is done, so the state is final
+	checkMembers(t, s, "#test", map[string]string{"tester": "", "bob": ""})
+}
+
+func TestDisconnectEndsStream(t *testing.T) {
+	s, srv := connect(t, Config{})
+	joinTest(t, s, srv)
+
+	srv.conn.Close()
+	nextEvent[Disconnected](t, s)
+	select {
+	case event, ok := <-s.Events():
+		if ok {
+			t.Errorf("event %#v after Disconnected", event)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("event stream not closed")
+	}
+	if got := s.Channels(); len(got) != 0 {
+		t.Errorf("channels after disconnect = %v", got)
+	}
+}
+
+func TestFailedConnectClosesStream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := New(Config{Server: addr, Nick: "tester"})
+	if err := s.Connect(); err == nil {
+		t.Fatal("connected to a closed port")
+	}
+	if _, ok := <-s.Events(); ok {
+		t.Error("event stream still open after a failed connect")
+	}
+}
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// channelState is what the session knows about a joined channel
+type channelState struct {
+	name         string
+	topic        string
+	topicSetBy   string
+	topicSetAt   time.Time
+	members      map[string]string // Nick -> membership prefix symbols, e.g. "@"
+	namesPending bool              // A NAMES reply is arriving and replaces the list
+}
+
+// state is the protocol state of a session. It is written by the goirc
+// handlers and read by callers on other goroutines, under Session.mu.
+type state struct {
+	nick      string
+	userModes string // Without the leading +, e.g. "iZ"
+	modes     Modes
+	channels  map[string]*channelState // Lower-case name -> channel
+}
+
+func (st *state) channel(name string) *channelState {
+	return st.channels[strings.ToLower(name)]
+}
+
+func (st *state) isMe(nick string) bool {
+	return strings.EqualFold(nick, st.nick)
+}
+
+func (c *channelState) findMember(nick string) (string, bool) {
+	if _, ok := c.members[nick]; ok {
+		return nick, true
+	}
+	for member := range c.members {
+		if strings.EqualFold(member, nick) {
+			return member, true
+		}
+	}
+	return "", false
+}
+
+func (c *channelState) removeMember(nick string) {
+	if existing, ok := c.findMember(nick); ok {
+		delete(c.members, existing)
+	}
+}
+
+func (st *state) join(nick, channel string) {
+	if st.isMe(nick) {
+		st.channels[strings.ToLower(channel)] = &channelState{name: channel, members: map[string]string{nick: ""}}
+		return
+	}
+	if c := st.channel(channel); c != nil {
+		c.removeMember(nick)
+		c.members[nick] = ""
+	}
+}
+
+func (st *state) part(nick, channel string) {
+	if st.isMe(nick) {
+		delete(st.channels, strings.ToLower(channel))
+		return
+	}
+	if c := st.channel(channel); c != nil {
+		c.removeMember(nick)
+	}
+}
+
+// quit removes a nick from every channel, returning the channels it was in
+func (st *state) quit(nick string) []string {
+	var channels []string
+	for _, c := range st.channels {
+		if existing, ok := c.findMember(nick); ok {
+			delete(c.members, existing)
+			channels = append(channels, c.name)
+		}
+	}
+	sortFold(channels)
+	return channels
+}
+
+// rename changes a nick in every channel, returning the channels it is in
+func (st *state) rename(oldNick, newNick string) []string {
+	if st.isMe(oldNick) {
+		st.nick = newNick
+	}
+	var channels []string
+	for _, c := range st.channels {
+		if existing, ok := c.findMember(oldNick); ok {
+			prefix := c.members[existing]
+			delete(c.members, existing)
+			c.members[newNick] = prefix
+			channels = append(channels, c.name)
+		}
+	}
+	sortFold(channels)
+	return channels
+}
+
+func (st *state) names(channel string, names []string) {
+	c := st.channel(channel)
+	if c == nil {
+		return
+	}
+	if !c.namesPending {
+		c.members = make(map[string]string)
+		c.namesPending = true
+	}
+	for _, name := range names {
+		if prefix, nick := st.modes.SplitPrefix(name); nick != "" {
+			c.removeMember(nick)
+			c.members[nick] = prefix
+		}
+	}
+}
+
+func (st *state) endOfNames(channel string) {
+	if c := st.channel(channel); c != nil {
+		c.namesPending = false
+	}
+}
+
+func (st *state) mode(target, modes string, args []string) {
+	if target == "" || !strings.ContainsRune("#&+!", rune(target[0])) {
+		if st.isMe(target) {
+			st.userModes = ApplyUserModes(st.userModes, modes)
+		}
+		return
+	}
+	c := st.channel(target)
+	if c == nil {
+		return
+	}
+	for _, change := range st.modes.ParseChanges(modes, args) {
+		nick, ok := c.findMember(change.Arg)
+		if !ok {
+			continue
+		}
+		if prefix, member := st.modes.ApplyChange(c.members[nick], change); member {
+			c.members[nick] = prefix
+		}
+	}
+}
+
+func (st *state) sortedChannels() []string {
+	names := make([]string, 0, len(st.channels))
+	for _, c := range st.channels {
+		names = append(names, c.name)
+	}
+	sortFold(names)
+	return names
+}
+
+// sortFold sorts names ignoring case
+func sortFold(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+}
@@ -2,347 +2,531 @@ package main
 
 import "github.com/charmbracelet/lipgloss"
 
+func init() {
+	applyTheme(builtinThemes[0])
+}
+
+// Theme colors, set by applyTheme
 var (
-	// Minimal text colors
-	textPrimary   = lipgloss.Color("#FFFFFF")
-	textSecondary = lipgloss.Color("#A0A0A0")
-	textMuted     = lipgloss.Color("#666666")
-
-	// Clean background colors
-	bgPrimary   = lipgloss.Color("#000000") // Pure black
-	bgSecondary = lipgloss.Color("#111111") // Slightly lighter
-
-	// Minimal border colors
-	borderColor      = lipgloss.Color("#333333")
-	borderColorFocus = lipgloss.Color("#666666")
+	textPrimary   lipgloss.Color
+	textSecondary lipgloss.Color
+	textMuted     lipgloss.Color
+
+	bgPrimary   lipgloss.Color
+	bgSecondary lipgloss.Color
+
+	borderColor      lipgloss.Color
+	borderColorFocus lipgloss.Color
 )
 
+// Every style is built from the theme colors by buildStyles
 var (
+	// Header, status and topic bars
+	headerStyle       lipgloss.Style
+	actHighlightStyle lipgloss.Style
+	actMessageStyle   lipgloss.Style
+	actActivityStyle  lipgloss.Style
+	statusStyle       lipgloss.Style
+	topicBarStyle     lipgloss.Style
+
+	// Chat messages
+	systemMessageStyle    lipgloss.Style
+	userMessageStyle      lipgloss.Style
+	ownMessageStyle       lipgloss.Style
+	joinMessageStyle      lipgloss.Style
+	partMessageStyle      lipgloss.Style
+	quitMessageStyle      lipgloss.Style
+	noticeMessageStyle    lipgloss.Style
+	errorMessageStyle     lipgloss.Style
+	actionMessageStyle    lipgloss.Style
+	ctcpMessageStyle      lipgloss.Style
+	kickMessageStyle      lipgloss.Style
+	modeMessageStyle      lipgloss.Style
+	topicMessageStyle     lipgloss.Style
+	channelSwitchStyle    lipgloss.Style
+	highlightMessageStyle lipgloss.Style
+	newMessagesStyle      lipgloss.Style
+	timestampStyle        lipgloss.Style
+
+	// Input box, chat area and help line
+	inputBoxStyle        lipgloss.Style
+	inputBoxFocusedStyle lipgloss.Style
+	chatAreaStyle        lipgloss.Style
+	helpStyle            lipgloss.Style
+
+	// Sidebar
+	sidebarStyle                lipgloss.Style
+	sidebarHeaderStyle          lipgloss.Style
+	sidebarItemStyle            lipgloss.Style
+	sidebarActiveItemStyle      lipgloss.Style
+	sidebarSectionStyle         lipgloss.Style
+	sidebarChannelCountStyle    lipgloss.Style
+	sidebarUnreadBadgeStyle     lipgloss.Style
+	sidebarHighlightBadgeStyle  lipgloss.Style
+	sidebarMessageItemStyle     lipgloss.Style
+	sidebarHighlightItemStyle   lipgloss.Style
+	sidebarStatusDotStyle       lipgloss.Style
+	sidebarDisconnectedDotStyle lipgloss.Style
+
+	// User list
+	nicklistStyle           lipgloss.Style
+	nicklistHeaderStyle     lipgloss.Style
+	nicklistItemStyle       lipgloss.Style
+	nicklistPrivilegedStyle lipgloss.Style
+
+	// Setup wizard
+	setupTitleStyle                  lipgloss.Style
+	setupSubtitleStyle               lipgloss.Style
+	setupStepHeaderStyle             lipgloss.Style
+	setupDescStyle                   lipgloss.Style
+	setupLabelStyle                  lipgloss.Style
+	setupHintStyle                   lipgloss.Style
+	setupExampleBoxStyle             lipgloss.Style
+	setupInfoBoxStyle                lipgloss.Style
+	setupSummaryBoxStyle             lipgloss.Style
+	setupInputBoxStyle               lipgloss.Style
+	setupActionStyle                 lipgloss.Style
+	setupFooterStyle                 lipgloss.Style
+	setupProgressContainerStyle      lipgloss.Style
+	setupProgressCompletedStyle      lipgloss.Style
+	setupProgressCurrentStyle        lipgloss.Style
+	setupProgressPendingStyle        lipgloss.Style
+	setupProgressLabelCompletedStyle lipgloss.Style
+	setupProgressLabelCurrentStyle   lipgloss.Style
+	setupProgressLabelPendingStyle   lipgloss.Style
+	setupWelcomeBoxStyle             lipgloss.Style
+	setupValidationStyle             lipgloss.Style
+
+	// Command palette
+	commandPaletteBorderStyle    lipgloss.Style
+	commandPaletteHeaderStyle    lipgloss.Style
+	commandPaletteQueryStyle     lipgloss.Style
+	commandPaletteItemStyle      lipgloss.Style
+	commandPaletteSelectedStyle  lipgloss.Style
+	commandPaletteFooterStyle    lipgloss.Style
+	commandPaletteSeparatorStyle lipgloss.Style
+	commandPaletteCategoryStyle  lipgloss.Style
+	commandPaletteEmptyStyle     lipgloss.Style
+)
+
+// buildStyles creates every style from the current theme colors
+func buildStyles() {
 	headerStyle = lipgloss.NewStyle().
-			Background(bgSecondary).
-			Foreground(textPrimary).
-			Padding(0, 2).
-			Width(100)
+		Background(bgSecondary).
+		Foreground(textPrimary).
+		Padding(0, 2).
+		Width(100)
+
+	// Entries of the header's Act: bar, on the header background
+	actHighlightStyle = lipgloss.NewStyle().
+		Background(bgSecondary).
+		Foreground(textPrimary).
+		Bold(true).
+		Underline(true)
+
+	actMessageStyle = lipgloss.NewStyle().
+		Background(bgSecondary).
+		Foreground(textPrimary)
+
+	actActivityStyle = lipgloss.NewStyle().
+		Background(bgSecondary).
+		Foreground(textMuted)
 
 	statusStyle = lipgloss.NewStyle().
-			Background(bgPrimary).
-			Foreground(textSecondary).
-			Padding(0, 1).
-			Width(100)
+		Background(bgPrimary).
+		Foreground(textSecondary).
+		Padding(0, 1).
+		Width(100)
+
+	topicBarStyle = lipgloss.NewStyle().
+		Background(bgSecondary).
+		Foreground(textSecondary).
+		Padding(0, 1).
+		Width(100).
+		MaxHeight(topicHeight)
 
 	systemMessageStyle = lipgloss.NewStyle().
-				Foreground(textSecondary)
+		Foreground(textSecondary)
 
 	userMessageStyle = lipgloss.NewStyle().
-				Foreground(textPrimary)
+		Foreground(textPrimary)
 
 	ownMessageStyle = lipgloss.NewStyle().
-			Foreground(textPrimary)
+		Foreground(textPrimary)
 
 	joinMessageStyle = lipgloss.NewStyle().
-				Foreground(textMuted)
+		Foreground(textMuted)
 
 	partMessageStyle = lipgloss.NewStyle().
-				Foreground(textMuted)
+		Foreground(textMuted)
 
 	quitMessageStyle = lipgloss.NewStyle().
-				Foreground(textMuted)
+		Foreground(textMuted)
 
 	noticeMessageStyle = lipgloss.NewStyle().
-				Foreground(textSecondary)
+		Foreground(textSecondary)
 
 	errorMessageStyle = lipgloss.NewStyle().
-				Foreground(textPrimary)
+		Foreground(textPrimary)
+
+	actionMessageStyle = lipgloss.NewStyle().
+		Foreground(textPrimary).
+		Italic(true)
+
+	ctcpMessageStyle = lipgloss.NewStyle().
+		Foreground(textMuted)
+
+	kickMessageStyle = lipgloss.NewStyle().
+		Foreground(textPrimary)
+
+	modeMessageStyle = lipgloss.NewStyle().
+		Foreground(textSecondary)
+
+	topicMessageStyle = lipgloss.NewStyle().
+		Foreground(textSecondary)
 
 	channelSwitchStyle = lipgloss.NewStyle().
-				Foreground(textSecondary)
+		Foreground(textSecondary)
+
+	highlightMessageStyle = lipgloss.NewStyle().
+		Foreground(bgPrimary).
+		Background(textSecondary)
+
+	newMessagesStyle = lipgloss.NewStyle().
+		Foreground(textPrimary).
+		Bold(true)
 
 	timestampStyle = lipgloss.NewStyle().
-			Foreground(textMuted)
+		Foreground(textMuted)
 
 	inputBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.NormalBorder()).
-			BorderForeground(borderColor).
-			Padding(0, 1).
-			Width(100)
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Padding(0, 1).
+		Width(100)
 
 	inputBoxFocusedStyle = lipgloss.NewStyle().
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(borderColorFocus).
-				Padding(0, 1).
-				Width(100)
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColorFocus).
+		Padding(0, 1).
+		Width(100)
 
 	chatAreaStyle = lipgloss.NewStyle().
-			Border(lipgloss.NormalBorder()).
-			BorderForeground(borderColor).
-			Padding(1, 2).
-			Background(bgPrimary)
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Padding(1, 2).
+		Background(bgPrimary)
 
 	helpStyle = lipgloss.NewStyle().
-			Foreground(textMuted).
-			Align(lipgloss.Center).
-			Padding(0, 1).
-			Margin(1, 0, 0, 0)
+		Foreground(textMuted).
+		Align(lipgloss.Center).
+		Padding(0, 1).
+		Margin(1, 0, 0, 0)
 
 	sidebarStyle = lipgloss.NewStyle().
-			Background(bgSecondary).
-			Border(lipgloss.NormalBorder(), false, true, false, false).
-			BorderForeground(borderColor).
-			Padding(1).
-			Width(30).
-			Height(20)
+		Background(bgSecondary).
+		Border(lipgloss.NormalBorder(), false, true, false, false).
+		BorderForeground(borderColor).
+		Padding(1).
+		Width(30).
+		Height(20)
 
 	sidebarHeaderStyle = lipgloss.NewStyle().
-				Foreground(textPrimary).
-				Padding(0, 1).
-				Margin(0, 0, 1, 0).
-				Width(26)
+		Foreground(textPrimary).
+		Padding(0, 1).
+		Margin(0, 0, 1, 0).
+		Width(26)
 
 	sidebarItemStyle = lipgloss.NewStyle().
-				Foreground(textSecondary).
-				Padding(0, 1).
-				Margin(0, 0, 0, 0)
+		Foreground(textSecondary).
+		Padding(0, 1).
+		Margin(0, 0, 0, 0)
 
 	sidebarActiveItemStyle = lipgloss.NewStyle().
-				Foreground(textPrimary).
-				Background(bgPrimary).
-				Padding(0, 1).
-				Margin(0, 0, 0, 0)
+		Foreground(textPrimary).
+		Background(bgPrimary).
+		Padding(0, 1).
+		Margin(0, 0, 0, 0)
 
 	sidebarSectionStyle = lipgloss.NewStyle().
-				Foreground(textPrimary).
-				Padding(0, 1).
-				Margin(0, 0, 0, 0)
+		Foreground(textPrimary).
+		Padding(0, 1).
+		Margin(0, 0, 0, 0)
 
 	sidebarChannelCountStyle = lipgloss.NewStyle().
-					Foreground(textSecondary).
-					Background(bgPrimary).
-					Padding(0, 1)
+		Foreground(textSecondary).
+		Background(bgPrimary).
+		Padding(0, 1)
+
+	sidebarUnreadBadgeStyle = lipgloss.NewStyle().
+		Foreground(textPrimary)
+
+	sidebarHighlightBadgeStyle = lipgloss.NewStyle().
+		Foreground(bgPrimary).
+		Background(textPrimary).
+		Bold(true)
+
+	// Sidebar entries of buffers with unseen messages or highlights
+	sidebarMessageItemStyle = lipgloss.NewStyle().
+		Foreground(textPrimary).
+		Padding(0, 1)
+
+	sidebarHighlightItemStyle = lipgloss.NewStyle().
+		Foreground(textPrimary).
+		Bold(true).
+		Padding(0, 1)
 
 	sidebarStatusDotStyle = lipgloss.NewStyle().
-				Foreground(textPrimary)
+		Foreground(textPrimary)
 
 	sidebarDisconnectedDotStyle = lipgloss.NewStyle().
-					Foreground(textMuted)
+		Foreground(textMuted)
+
+	nicklistStyle = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), true, true, true, false).
+		BorderForeground(borderColor).
+		Padding(0, 1).
+		Width(nicklistWidth - 1)
+
+	nicklistHeaderStyle = lipgloss.NewStyle().
+		Foreground(textPrimary).
+		Margin(0, 0, 0, 0)
+
+	nicklistItemStyle = lipgloss.NewStyle().
+		Foreground(textSecondary)
+
+	nicklistPrivilegedStyle = lipgloss.NewStyle().
+		Foreground(textPrimary)
 
 	// Setup wizard styles - Minimal design
 	setupTitleStyle = lipgloss.NewStyle().
-			Foreground(textPrimary).
-			Padding(1, 0).
-			Margin(1, 0, 2, 0).
-			Align(lipgloss.Center).
-			Width(80)
+		Foreground(textPrimary).
+		Padding(1, 0).
+		Margin(1, 0, 2, 0).
+		Align(lipgloss.Center).
+		Width(80)
 
 	setupSubtitleStyle = lipgloss.NewStyle().
-				Foreground(textSecondary).
-				Align(lipgloss.Center).
-				Width(80).
-				Margin(0, 0, 2, 0)
+		Foreground(textSecondary).
+		Align(lipgloss.Center).
+		Width(80).
+		Margin(0, 0, 2, 0)
 
 	setupStepHeaderStyle = lipgloss.NewStyle().
-				Foreground(textPrimary).
-				Padding(1, 0).
-				Margin(1, 0, 1, 0).
-				Width(70)
+		Foreground(textPrimary).
+		Padding(1, 0).
+		Margin(1, 0, 1, 0).
+		Width(70)
 
 	setupDescStyle = lipgloss.NewStyle().
-			Foreground(textSecondary).
-			Margin(0, 0, 2, 0).
-			Width(70)
+		Foreground(textSecondary).
+		Margin(0, 0, 2, 0).
+		Width(70)
 
 	setupLabelStyle = lipgloss.NewStyle().
-			Foreground(textPrimary).
-			Margin(1, 0, 0, 0)
+		Foreground(textPrimary).
+		Margin(1, 0, 0, 0)
 
 	setupHintStyle = lipgloss.NewStyle().
-			Foreground(textMuted).
-			Margin(0, 0, 1, 0)
+		Foreground(textMuted).
+		Margin(0, 0, 1, 0)
 
 	setupExampleBoxStyle = lipgloss.NewStyle().
-				Background(bgSecondary).
-				Foreground(textSecondary).
-				Padding(1, 2).
-				Margin(1, 0, 2, 0).
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(borderColor).
-				Width(70)
+		Background(bgSecondary).
+		Foreground(textSecondary).
+		Padding(1, 2).
+		Margin(1, 0, 2, 0).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Width(70)
 
 	setupInfoBoxStyle = lipgloss.NewStyle().
-				Background(bgSecondary).
-				Foreground(textSecondary).
-				Padding(1, 2).
-				Margin(1, 0, 2, 0).
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(borderColor).
-				Width(70)
+		Background(bgSecondary).
+		Foreground(textSecondary).
+		Padding(1, 2).
+		Margin(1, 0, 2, 0).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Width(70)
 
 	setupSummaryBoxStyle = lipgloss.NewStyle().
-				Background(bgSecondary).
-				Foreground(textPrimary).
-				Padding(2, 3).
-				Margin(1, 0, 2, 0).
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(borderColor).
-				Width(70)
+		Background(bgSecondary).
+		Foreground(textPrimary).
+		Padding(2, 3).
+		Margin(1, 0, 2, 0).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Width(70)
 
 	setupInputBoxStyle = lipgloss.NewStyle().
-				Background(bgPrimary).
-				Foreground(textPrimary).
-				Padding(0, 1).
-				Margin(1, 0, 2, 0).
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(borderColor).
-				Width(70)
+		Background(bgPrimary).
+		Foreground(textPrimary).
+		Padding(0, 1).
+		Margin(1, 0, 2, 0).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Width(70)
 
 	setupActionStyle = lipgloss.NewStyle().
-				Foreground(textPrimary).
-				Padding(1, 2).
-				Margin(1, 0).
-				Align(lipgloss.Center).
-				Width(70)
+		Foreground(textPrimary).
+		Padding(1, 2).
+		Margin(1, 0).
+		Align(lipgloss.Center).
+		Width(70)
 
 	setupFooterStyle = lipgloss.NewStyle().
-				Foreground(textMuted).
-				Padding(1, 2).
-				Margin(2, 0, 0, 0).
-				Align(lipgloss.Center).
-				Width(80)
+		Foreground(textMuted).
+		Padding(1, 2).
+		Margin(2, 0, 0, 0).
+		Align(lipgloss.Center).
+		Width(80)
 
 	// Progress bar styles - Minimal design
 	setupProgressContainerStyle = lipgloss.NewStyle().
-					Align(lipgloss.Center).
-					Margin(1, 0, 2, 0).
-					Padding(1, 0).
-					Width(78)
+		Align(lipgloss.Center).
+		Margin(1, 0, 2, 0).
+		Padding(1, 0).
+		Width(78)
 
 	setupProgressCompletedStyle = lipgloss.NewStyle().
-					Foreground(textPrimary)
+		Foreground(textPrimary)
 
 	setupProgressCurrentStyle = lipgloss.NewStyle().
-					Foreground(textPrimary)
+		Foreground(textPrimary)
 
 	setupProgressPendingStyle = lipgloss.NewStyle().
-					Foreground(textMuted)
+		Foreground(textMuted)
 
 	setupProgressLabelCompletedStyle = lipgloss.NewStyle().
-						Foreground(textPrimary).
-						Width(12).
-						Align(lipgloss.Center)
+		Foreground(textPrimary).
+		Width(12).
+		Align(lipgloss.Center)
 
 	setupProgressLabelCurrentStyle = lipgloss.NewStyle().
-					Foreground(textPrimary).
-					Width(12).
-					Align(lipgloss.Center)
+		Foreground(textPrimary).
+		Width(12).
+		Align(lipgloss.Center)
 
 	setupProgressLabelPendingStyle = lipgloss.NewStyle().
-					Foreground(textMuted).
-					Width(12).
-					Align(lipgloss.Center)
+		Foreground(textMuted).
+		Width(12).
+		Align(lipgloss.Center)
 
 	// Minimal setup experience styles
 	setupWelcomeBoxStyle = lipgloss.NewStyle().
-				Background(bgSecondary).
-				Foreground(textPrimary).
-				Padding(2, 3).
-				Margin(1, 0, 2, 0).
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(borderColor).
-				Width(80)
+		Background(bgSecondary).
+		Foreground(textPrimary).
+		Padding(2, 3).
+		Margin(1, 0, 2, 0).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Width(80)
 
 	setupValidationStyle = lipgloss.NewStyle().
-				Foreground(textPrimary).
-				Margin(0, 0, 1, 0)
+		Foreground(textPrimary).
+		Margin(0, 0, 1, 0)
 
 	// Command palette styles - Minimal design
 	commandPaletteBorderStyle = lipgloss.NewStyle().
-					Background(bgPrimary).
-					Border(lipgloss.NormalBorder()).
-					BorderForeground(borderColor).
-					Padding(1, 2).
-					Width(84).
-					Height(20)
+		Background(bgPrimary).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor).
+		Padding(1, 2).
+		Width(84)
 
 	commandPaletteHeaderStyle = lipgloss.NewStyle().
-					Foreground(textPrimary).
-					Padding(0, 1).
-					Width(76).
-					Margin(0, 0, 1, 0)
+		Foreground(textPrimary).
+		Padding(0, 1).
+		Width(76).
+		Margin(0, 0, 1, 0)
 
 	commandPaletteQueryStyle = lipgloss.NewStyle().
-					Foreground(textPrimary).
-					Background(bgSecondary).
-					Width(76).
-					Padding(0, 1).
-					Margin(0, 0, 1, 0).
-					Border(lipgloss.NormalBorder()).
-					BorderForeground(borderColor)
+		Foreground(textPrimary).
+		Background(bgSecondary).
+		Width(76).
+		Padding(0, 1).
+		Margin(0, 0, 1, 0).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(borderColor)
 
 	commandPaletteItemStyle = lipgloss.NewStyle().
-				Foreground(textSecondary).
-				Padding(0, 1).
-				Width(76).
-				Margin(0, 0, 0, 0)
+		Foreground(textSecondary).
+		Padding(0, 1).
+		Width(76).
+		Margin(0, 0, 0, 0)
 
 	commandPaletteSelectedStyle = lipgloss.NewStyle().
-					Background(bgSecondary).
-					Foreground(textPrimary).
-					Padding(0, 1).
-					Width(76)
+		Background(bgSecondary).
+		Foreground(textPrimary).
+		Padding(0, 1).
+		Width(76)
 
 	commandPaletteFooterStyle = lipgloss.NewStyle().
-					Foreground(textMuted).
-					Padding(0, 1).
-					Width(76).
-					Margin(1, 0, 0, 0)
+		Foreground(textMuted).
+		Padding(0, 1).
+		Width(76).
+		Margin(1, 0, 0, 0)
 
 	commandPaletteSeparatorStyle = lipgloss.NewStyle().
-					Foreground(textSecondary).
-					Width(76).
-					Padding(0, 1).
-					Margin(0, 0, 1, 0).
-					Align(lipgloss.Center)
+		Foreground(textSecondary).
+		Width(76).
+		Padding(0, 1).
+		Margin(0, 0, 1, 0).
+		Align(lipgloss.Center)
 
 	commandPaletteCategoryStyle = lipgloss.NewStyle().
-					Foreground(textPrimary).
-					Padding(0, 1).
-					Margin(0, 0, 0, 0).
-					Width(74)
+		Foreground(textPrimary).
+		Padding(0, 1).
+		Margin(0, 0, 0, 0).
+		Width(74)
 
 	commandPaletteEmptyStyle = lipgloss.NewStyle().
-					Foreground(textMuted).
-					Align(lipgloss.Center).
-					Width(76).
-					Padding(1, 1).
-					Margin(1, 0, 1, 0)
-)
-
-func UpdateStyleWidths(width int) {
-	sidebarWidth := 30
-	if width < 120 {
-		sidebarWidth = 25 // Smaller sidebar for narrow screens
-	}
+		Foreground(textMuted).
+		Align(lipgloss.Center).
+		Width(76).
+		Padding(1, 1).
+		Margin(1, 0, 1, 0)
+}
 
-	chatWidth := width - sidebarWidth - 4 // Account for sidebar width and borders
-	if chatWidth < 40 {
-		chatWidth = 40 // Minimum chat width
+// UpdateStyleWidths sizes the styles for a terminal width columns wide,
+// sidebarWidth of which go to the sidebar, or none when it is 0
+func UpdateStyleWidths(width, sidebarWidth int) {
+	mainWidth := width
+	if sidebarWidth > 0 {
+		mainWidth -= sidebarWidth + sidebarStyle.GetHorizontalBorderSize()
 	}
 
 	headerStyle = headerStyle.Width(width)
 	statusStyle = statusStyle.Width(width)
-	inputBoxStyle = inputBoxStyle.Width(chatWidth)
-	inputBoxFocusedStyle = inputBoxFocusedStyle.Width(chatWidth)
-	chatAreaStyle = chatAreaStyle.Width(chatWidth)
-	sidebarStyle = sidebarStyle.Width(sidebarWidth)
-	sidebarHeaderStyle = sidebarHeaderStyle.Width(sidebarWidth - 4)
-
-	// Update command palette styles to be responsive
-	paletteWidth := 84
-	if width > 0 && paletteWidth > width-6 {
-		paletteWidth = width - 6
+	topicBarStyle = topicBarStyle.Width(width)
+	inputBoxStyle = inputBoxStyle.Width(mainWidth - inputBoxStyle.GetHorizontalBorderSize())
+	inputBoxFocusedStyle = inputBoxFocusedStyle.Width(mainWidth - inputBoxFocusedStyle.GetHorizontalBorderSize())
+	chatAreaStyle = chatAreaStyle.Width(mainWidth - chatAreaStyle.GetHorizontalBorderSize())
+	helpStyle = helpStyle.Width(mainWidth)
+	if sidebarWidth > 0 {
+		sidebarStyle = sidebarStyle.Width(sidebarWidth)
+		sidebarHeaderStyle = sidebarHeaderStyle.Width(sidebarWidth - 4)
 	}
-	if paletteWidth < 60 {
-		paletteWidth = 60
+
+	// The setup wizard keeps its widths unless the terminal is narrower
+	fit := func(style lipgloss.Style, want int) lipgloss.Style {
+		return style.Width(min(want, width-style.GetHorizontalBorderSize()))
 	}
+	setupWelcomeBoxStyle = fit(setupWelcomeBoxStyle, 80)
+	setupTitleStyle = fit(setupTitleStyle, 80)
+	setupSubtitleStyle = fit(setupSubtitleStyle, 80)
+	setupProgressContainerStyle = fit(setupProgressContainerStyle, 78)
+	setupStepHeaderStyle = fit(setupStepHeaderStyle, 70)
+	setupDescStyle = fit(setupDescStyle, 70)
+	setupExampleBoxStyle = fit(setupExampleBoxStyle, 70)
+	setupInfoBoxStyle = fit(setupInfoBoxStyle, 70)
+	setupSummaryBoxStyle = fit(setupSummaryBoxStyle, 70)
+	setupInputBoxStyle = fit(setupInputBoxStyle, 70)
+	setupActionStyle = fit(setupActionStyle, 70)
+	setupFooterStyle = fit(setupFooterStyle, 80)
+
+	// The palette floats over the screen, border and all
+	paletteWidth := max(min(84, width-commandPaletteBorderStyle.GetHorizontalBorderSize()), 30)
 
 	commandPaletteBorderStyle = commandPaletteBorderStyle.Width(paletteWidth)
 	commandPaletteHeaderStyle = commandPaletteHeaderStyle.Width(paletteWidth - 8)
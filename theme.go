@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// themeAuto picks the dark or light theme to suit the terminal background
+const themeAuto = "auto"
+
+// Theme is a color scheme. The colors drive every style; Styles can then
+// adjust single styles by name, e.g. "highlight_message" or "sidebar_item".
+// Themes are built in, or read from <config dir>/themes/<name>.json.
+type Theme struct {
+	Name       string                   `json:"name"`
+	Colors     ThemeColors              `json:"colors"`
+	NickColors []string                 `json:"nick_colors,omitempty"` // Sender colors, picked by nick
+	Styles     map[string]StyleOverride `json:"styles,omitempty"`
+}
+
+// ThemeColors are the base colors styles are made of. Colors are hex
+// ("#RRGGBB") or ANSI numbers ("0"-"255"); any left empty in a theme file
+// are taken from the dark theme.
+type ThemeColors struct {
+	TextPrimary   string `json:"text_primary"`
+	TextSecondary string `json:"text_secondary"`
+	TextMuted     string `json:"text_muted"`
+	BgPrimary     string `json:"bg_primary"`
+	BgSecondary   string `json:"bg_secondary"`
+	Border        string `json:"border"`
+	BorderFocus   string `json:"border_focus"`
+}
+
+// StyleOverride changes parts of one style; unset fields keep the value
+// built from the theme colors
+type StyleOverride struct {
+	Foreground string `json:"fg,omitempty"`
+	Background string `json:"bg,omitempty"`
+	Border     string `json:"border,omitempty"`
+	Bold       *bool  `json:"bold,omitempty"`
+	Italic     *bool  `json:"italic,omitempty"`
+	Underline  *bool  `json:"underline,omitempty"`
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// builtinThemes come with the client; the first is the default
+var builtinThemes = []Theme{
+	{
+		Name: "dark",
+		Colors: ThemeColors{
+			TextPrimary:   "#FFFFFF",
+			TextSecondary: "#A0A0A0",
+			TextMuted:     "#666666",
+			BgPrimary:     "#000000",
+			BgSecondary:   "#111111",
+			Border:        "#333333",
+			BorderFocus:   "#666666",
+		},
+		NickColors: []string{"#E06C75", "#98C379", "#E5C07B", "#61AFEF", "#C678DD", "#56B6C2", "#D19A66", "#F0A0C0"},
+	},
+	{
+		Name: "light",
+		Colors: ThemeColors{
+			TextPrimary:   "#1A1A1A",
+			TextSecondary: "#4A4A4A",
+			TextMuted:     "#8A8A8A",
+			BgPrimary:     "#FFFFFF",
+			BgSecondary:   "#EEEEEE",
+			Border:        "#CCCCCC",
+			BorderFocus:   "#888888",
+		},
+		NickColors: []string{"#B3261E", "#1E7B34", "#8A6D00", "#1F5FBF", "#7B2FA8", "#0F7C85", "#A64D00", "#9E2A6B"},
+	},
+	{
+		Name: "high-contrast",
+		Colors: ThemeColors{
+			TextPrimary:   "#FFFFFF",
+			TextSecondary: "#FFFFFF",
+			TextMuted:     "#C0C0C0",
+			BgPrimary:     "#000000",
+			BgSecondary:   "#000000",
+			Border:        "#FFFFFF",
+			BorderFocus:   "#FFFF00",
+		},
+		NickColors: []string{"#FFFF00", "#00FFFF", "#FF00FF", "#00FF00", "#FF8000", "#80C0FF"},
+		Styles: map[string]StyleOverride{
+			"highlight_message":        {Foreground: "#000000", Background: "#FFFF00", Bold: boolPtr(true)},
+			"sidebar_active_item":      {Foreground: "#000000", Background: "#FFFFFF", Bold: boolPtr(true)},
+			"command_palette_selected": {Foreground: "#000000", Background: "#FFFF00"},
+			"error_message":            {Foreground: "#FF4040", Bold: boolPtr(true)},
+		},
+	},
+	{
+		Name: "solarized",
+		Colors: ThemeColors{
+			TextPrimary:   "#93A1A1",
+			TextSecondary: "#839496",
+			TextMuted:     "#586E75",
+			BgPrimary:     "#002B36",
+			BgSecondary:   "#073642",
+			Border:        "#586E75",
+			BorderFocus:   "#268BD2",
+		},
+		NickColors: solarizedAccents,
+		Styles: map[string]StyleOverride{
+			"highlight_message": {Foreground: "#002B36", Background: "#B58900"},
+			"error_message":     {Foreground: "#DC322F"},
+		},
+	},
+	{
+		Name: "solarized-light",
+		Colors: ThemeColors{
+			TextPrimary:   "#586E75",
+			TextSecondary: "#657B83",
+			TextMuted:     "#93A1A1",
+			BgPrimary:     "#FDF6E3",
+			BgSecondary:   "#EEE8D5",
+			Border:        "#93A1A1",
+			BorderFocus:   "#268BD2",
+		},
+		NickColors: solarizedAccents,
+		Styles: map[string]StyleOverride{
+			"highlight_message": {Foreground: "#FDF6E3", Background: "#B58900"},
+			"error_message":     {Foreground: "#DC322F"},
+		},
+	},
+}
+
+var solarizedAccents = []string{"#B58900", "#CB4B16", "#DC322F", "#D33682", "#6C71C4", "#268BD2", "#2AA198", "#859900"}
+
+// themedStyles names every style for theme overrides
+var themedStyles = map[string]*lipgloss.Style{
+	"header":                         &headerStyle,
+	"act_highlight":                  &actHighlightStyle,
+	"act_message":                    &actMessageStyle,
+	"act_activity":                   &actActivityStyle,
+	"status":                         &statusStyle,
+	"topic_bar":                      &topicBarStyle,
+	"system_message":                 &systemMessageStyle,
+	"user_message":                   &userMessageStyle,
+	"own_message":                    &ownMessageStyle,
+	"join_message":                   &joinMessageStyle,
+	"part_message":                   &partMessageStyle,
+	"quit_message":                   &quitMessageStyle,
+	"notice_message":                 &noticeMessageStyle,
+	"error_message":                  &errorMessageStyle,
+	"action_message":                 &actionMessageStyle,
+	"ctcp_message":                   &ctcpMessageStyle,
+	"kick_message":                   &kickMessageStyle,
+	"mode_message":                   &modeMessageStyle,
+	"topic_message":                  &topicMessageStyle,
+	"channel_switch":                 &channelSwitchStyle,
+	"highlight_message":              &highlightMessageStyle,
+	"new_messages":                   &newMessagesStyle,
+	"timestamp":                      &timestampStyle,
+	"input_box":                      &inputBoxStyle,
+	"input_box_focused":              &inputBoxFocusedStyle,
+	"chat_area":                      &chatAreaStyle,
+	"help":                           &helpStyle,
+	"sidebar":                        &sidebarStyle,
+	"sidebar_header":                 &sidebarHeaderStyle,
+	"sidebar_item":                   &sidebarItemStyle,
+	"sidebar_active_item":            &sidebarActiveItemStyle,
+	"sidebar_section":                &sidebarSectionStyle,
+	"sidebar_channel_count":          &sidebarChannelCountStyle,
+	"sidebar_unread_badge":           &sidebarUnreadBadgeStyle,
+	"sidebar_highlight_badge":        &sidebarHighlightBadgeStyle,
+	"sidebar_message_item":           &sidebarMessageItemStyle,
+	"sidebar_highlight_item":         &sidebarHighlightItemStyle,
+	"sidebar_status_dot":             &sidebarStatusDotStyle,
+	"sidebar_disconnected_dot":       &sidebarDisconnectedDotStyle,
+	"nicklist":                       &nicklistStyle,
+	"nicklist_header":                &nicklistHeaderStyle,
+	"nicklist_item":                  &nicklistItemStyle,
+	"nicklist_privileged":            &nicklistPrivilegedStyle,
+	"setup_title":                    &setupTitleStyle,
+	"setup_subtitle":                 &setupSubtitleStyle,
+	"setup_step_header":              &setupStepHeaderStyle,
+	"setup_desc":                     &setupDescStyle,
+	"setup_label":                    &setupLabelStyle,
+	"setup_hint":                     &setupHintStyle,
+	"setup_example_box":              &setupExampleBoxStyle,
+	"setup_info_box":                 &setupInfoBoxStyle,
+	"setup_summary_box":              &setupSummaryBoxStyle,
+	"setup_input_box":                &setupInputBoxStyle,
+	"setup_action":                   &setupActionStyle,
+	"setup_footer":                   &setupFooterStyle,
+	"setup_progress_container":       &setupProgressContainerStyle,
+	"setup_progress_completed":       &setupProgressCompletedStyle,
+	"setup_progress_current":         &setupProgressCurrentStyle,
+	"setup_progress_pending":         &setupProgressPendingStyle,
+	"setup_progress_label_completed": &setupProgressLabelCompletedStyle,
+	"setup_progress_label_current":   &setupProgressLabelCurrentStyle,
+	"setup_progress_label_pending":   &setupProgressLabelPendingStyle,
+	"setup_welcome_box":              &setupWelcomeBoxStyle,
+	"setup_validation":               &setupValidationStyle,
+	"command_palette_border":         &commandPaletteBorderStyle,
+	"command_palette_header":         &commandPaletteHeaderStyle,
+	"command_palette_query":          &commandPaletteQueryStyle,
+	"command_palette_item":           &commandPaletteItemStyle,
+	"command_palette_selected":       &commandPaletteSelectedStyle,
+	"command_palette_footer":         &commandPaletteFooterStyle,
+	"command_palette_separator":      &commandPaletteSeparatorStyle,
+	"command_palette_category":       &commandPaletteCategoryStyle,
+	"command_palette_empty":          &commandPaletteEmptyStyle,
+}
+
+var (
+	currentTheme = builtinThemes[0].Name
+	nickColors   []string
+)
+
+// applyTheme sets the theme colors and rebuilds every style from them.
+// Widths are reset too, so callers showing a UI must lay it out again.
+func applyTheme(t Theme) {
+	textPrimary = lipgloss.Color(t.Colors.TextPrimary)
+	textSecondary = lipgloss.Color(t.Colors.TextSecondary)
+	textMuted = lipgloss.Color(t.Colors.TextMuted)
+	bgPrimary = lipgloss.Color(t.Colors.BgPrimary)
+	bgSecondary = lipgloss.Color(t.Colors.BgSecondary)
+	borderColor = lipgloss.Color(t.Colors.Border)
+	borderColorFocus = lipgloss.Color(t.Colors.BorderFocus)
+
+	buildStyles()
+	for name, override := range t.Styles {
+		if style, ok := themedStyles[name]; ok {
+			*style = override.apply(*style)
+		}
+	}
+	nickColors = t.NickColors
+	currentTheme = t.Name
+}
+
+// apply layers the override onto a style
+func (o StyleOverride) apply(style lipgloss.Style) lipgloss.Style {
+	if o.Foreground != "" {
+		style = style.Foreground(lipgloss.Color(o.Foreground))
+	}
+	if o.Background != "" {
+		style = style.Background(lipgloss.Color(o.Background))
+	}
+	if o.Border != "" {
+		style = style.BorderForeground(lipgloss.Color(o.Border))
+	}
+	if o.Bold != nil {
+		style = style.Bold(*o.Bold)
+	}
+	if o.Italic != nil {
+		style = style.Italic(*o.Italic)
+	}
+	if o.Underline != nil {
+		style = style.Underline(*o.Underline)
+	}
+	return style
+}
+
+// nickColor picks a color for a nick from the theme, the same one every
+// time; empty when the theme has no nick colors
+func nickColor(nick string) string {
+	if len(nickColors) == 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(nick)))
+	return nickColors[h.Sum32()%uint32(len(nickColors))]
+}
+
+// GetThemeDir returns the directory theme files are read from
+func GetThemeDir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "themes"), nil
+}
+
+// loadTheme finds a theme by name. Files in the theme directory come
+// before built-in themes of the same name, so those can be customised.
+func loadTheme(name string) (Theme, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == themeAuto {
+		name = detectTheme()
+	}
+	if dir, err := GetThemeDir(); err == nil {
+		path := filepath.Join(dir, name+".json")
+		if data, err := os.ReadFile(path); err == nil {
+			return parseTheme(name, data)
+		} else if !os.IsNotExist(err) {
+			return Theme{}, fmt.Errorf("failed to read theme %s: %w", name, err)
+		}
+	}
+	for _, t := range builtinThemes {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Theme{}, fmt.Errorf("unknown theme %q (try /theme list)", name)
+}
+
+// parseTheme reads a theme file, filling colors it leaves out from the
+// default theme
+func parseTheme(name string, data []byte) (Theme, error) {
+	var t Theme
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Theme{}, fmt.Errorf("failed to parse theme %s: %w", name, err)
+	}
+	t.Name = name
+	defaults := builtinThemes[0].Colors
+	fill := func(color *string, fallback string) {
+		if *color == "" {
+			*color = fallback
+		}
+	}
+	fill(&t.Colors.TextPrimary, defaults.TextPrimary)
+	fill(&t.Colors.TextSecondary, defaults.TextSecondary)
+	fill(&t.Colors.TextMuted, defaults.TextMuted)
+	fill(&t.Colors.BgPrimary, defaults.BgPrimary)
+	fill(&t.Colors.BgSecondary, defaults.BgSecondary)
+	fill(&t.Colors.Border, defaults.Border)
+	fill(&t.Colors.BorderFocus, defaults.BorderFocus)
+	for style := range t.Styles {
+		if _, ok := themedStyles[style]; !ok {
+			return Theme{}, fmt.Errorf("theme %s: unknown style %q", name, style)
+		}
+	}
+	return t, nil
+}
+
+// themeNames lists the built-in themes followed by any theme files
+func themeNames() []string {
+	names := []string{themeAuto}
+	seen := map[string]bool{themeAuto: true}
+	for _, t := range builtinThemes {
+		names = append(names, t.Name)
+		seen[t.Name] = true
+	}
+	var files []string
+	if dir, err := GetThemeDir(); err == nil {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(strings.ToLower(entry.Name()), ".json")
+			if ok && !entry.IsDir() && !seen[name] {
+				files = append(files, name)
+			}
+		}
+	}
+	sort.Strings(files)
+	return append(names, files...)
+}
+
+// detectTheme asks the terminal for its background color. The answer is
+// cached, so it is first asked before the UI takes over the terminal.
+func detectTheme() string {
+	if lipgloss.HasDarkBackground() {
+		return "dark"
+	}
+	return "light"
+}
+
+// setTheme applies a theme by name and lays the UI out again with it
+func (m *model) setTheme(name string) error {
+	t, err := loadTheme(name)
+	if err != nil {
+		return err
+	}
+	applyTheme(t)
+	m.updateDimensions()
+	return nil
+}
+
+// handleThemeCommand runs /theme [name|list]
+func (m *model) handleThemeCommand(args []string) {
+	if len(args) == 0 {
+		setting := string(m.config.UI.Theme)
+		m.addMessage(formatSystemMessage(fmt.Sprintf("Theme: %s (setting: %s). Use /theme <name> or /theme list", currentTheme, setting)))
+		return
+	}
+	if strings.EqualFold(args[0], "list") {
+		m.addMessage(formatSystemMessage("Themes: " + strings.Join(themeNames(), ", ")))
+		if dir, err := GetThemeDir(); err == nil {
+			m.addMessage(formatSystemMessage("Theme files are read from " + dir))
+		}
+		return
+	}
+	name := strings.ToLower(args[0])
+	if err := m.setTheme(name); err != nil {
+		m.addMessage(formatErrorMessage(err.Error()))
+		return
+	}
+	m.config.UI.Theme = ThemeSetting(name)
+	m.saveConfig()
+	m.addMessage(formatSystemMessage(fmt.Sprintf("Theme set to %s", currentTheme)))
+}
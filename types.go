@@ -4,18 +4,19 @@ import (
 	"time"
 
 	"github.com/charmbracelet/bubbles/textarea"
-	"github.com/charmbracelet/bubbles/viewport"
-	tea "github.com/charmbracelet/bubbletea"
-	irc "github.com/fluffle/goirc/client"
+
+	"goirc/session"
 )
 
 const (
 	defaultServer  = "irc.libera.chat:6697"
 	defaultChannel = "#bubbletea-test"
 	defaultNick    = "bubbletea-user"
-	headerHeight   = 4
-	footerHeight   = 4
+	headerHeight   = 1
+	footerHeight   = 5 // The input box and the help line under it
 	statusHeight   = 1
+	topicHeight    = 1
+	minChatWidth   = 30 // Narrowest the chat gets before panes are hidden
 	minWidth       = 80
 	minHeight      = 24
 )
@@ -38,13 +39,31 @@ const (
 	setupConfirm
 )
 
-var p *tea.Program
-
 type channelData struct {
+	network  string // Name of the network the buffer belongs to
 	name     string
-	messages []string
+	messages *messageRing
 	active   bool
 	joined   bool
+	isQuery  bool // Private conversation with a single nick
+
+	rejoining bool // Auto-rejoin after a kick is in flight
+
+	// Copies of the session's member list and topic, kept up to date from
+	// its events so that drawing never has to ask the session
+	members    map[string]string // Nick -> membership prefix symbols
+	nicklist   []string          // Members as the nick list shows them, see sortedMembers
+	topic      string
+	topicSetBy string
+	topicSetAt time.Time
+
+	scroll scrollState          // Read position while out of view
+	spoke  map[string]time.Time // When each nick (lower case) last spoke, for the smart filter
+
+	// Activity since the buffer was last viewed
+	level      notifyLevel
+	unread     int // Messages from others
+	highlights int
 }
 
 type commandPaletteItem struct {
@@ -58,20 +77,20 @@ type commandPaletteItem struct {
 }
 
 type model struct {
-	ircClient      *irc.Conn
-	viewport       viewport.Model
-	messages       []string
-	textarea       textarea.Model
-	ready          bool
-	err            error
-	connectionTime time.Time
-	connected      bool
-	currentChannel string
-	currentNick    string
-	width          int
-	height         int
-
-	// Channel management
+	viewport      chatView
+	messages      *messageRing // History of the buffer in view
+	textarea      textarea.Model
+	ready         bool
+	err           error
+	currentBuffer string // Key of the buffer in view, see bufferKey
+	width         int
+	height        int
+
+	// One connection per configured network
+	networks     map[string]*network
+	networkOrder []string
+
+	// Buffer management, keyed by bufferKey(network, name)
 	channels       map[string]*channelData
 	channelOrder   []string
 	activeChannels []string
@@ -79,6 +98,20 @@ type model struct {
 	// UI state
 	showSidebar  bool
 	sidebarWidth int
+	showNicklist bool
+	completion   *completionState // Tab completion cycle in progress
+
+	// Input history
+	history *inputHistory
+	browse  *historyBrowse // Up/Down walk in progress
+	search  *historySearch // Ctrl+R search in progress
+
+	formatChord bool // Ctrl+X pressed; the next key picks a formatting code
+
+	ignores *ignoreList  // Shared with the IRC handlers
+	events  *eventStream // IRC events on their way from the handlers to Update
+
+	newBelow int // Messages added below the view while scrolled up
 
 	// Command palette
 	commandPaletteVisible  bool
@@ -89,20 +122,82 @@ type model struct {
 
 	state                appState
 	setupPhase           setupPhase
-	config               *Config // Updated to use the new Config struct
+	config               *Config    // Updated to use the new Config struct
+	loadedIRC            *IRCConfig // Primary network as loaded, when the command line overrode it
 	setupPrompt          string
 	setupValidationError string // For showing validation errors in setup
 	autoJoinChannels     []string
 	logger               *Logger // Add logger instance
+	chatLog              *chatLogger
+	highlighter          *highlighter
+	pendingAlert         string // Bell and notification sequences drawn ahead of the screen
+	alertGen             int    // Invalidates alertDoneMsg ticks from earlier alerts
 }
 
 type (
-	ircMessageMsg      string
-	ircPrivmsgMsg      struct{ user, message, channel string }
-	ircErrorMsg        struct{ err error }
-	ircConnectedMsg    struct{}
-	ircDisconnectedMsg struct{}
-	ircNickChangeMsg   struct{ oldNick, newNick string }
-	ircJoinMsg         struct{ user, channel string }
-	ircClientReadyMsg  struct{ client *irc.Conn }
+	// ircAuthenticatedMsg is the server's SASL success message
+	ircAuthenticatedMsg struct{ network, message string }
+	ircNoticeMsg        struct{ network, user, target, message string }
+	ircPrivmsgMsg       struct{ network, user, message, channel string }
+	ircErrorMsg         struct {
+		network string
+		err     error
+	}
+	ircConnectFailedMsg struct {
+		network string
+		err     error
+	}
+	ircConnectedMsg    struct{ network, nick string }
+	ircDisconnectedMsg struct{ network string }
+	// hidden is set for events from ignored users; the session has
+	// still updated its member lists but they are not shown. channels
+	// are the ones the user shared with us.
+	ircNickChangeMsg struct {
+		network, oldNick, newNick string
+		channels                  []string
+		hidden                    bool
+	}
+	ircJoinMsg struct {
+		network, user, channel string
+		hidden                 bool
+	}
+	ircPartMsg struct {
+		network, user, channel, message string
+		hidden                          bool
+	}
+	ircQuitMsg struct {
+		network, user, message string
+		channels               []string
+		hidden                 bool
+	}
+	ircKickMsg struct{ network, kicker, channel, target, reason string }
+	ircModeMsg struct {
+		network, setter, target, modes string
+		args                           []string
+	}
+	// ircMembersMsg is the session's copy of a channel's member list
+	// after a change to it
+	ircMembersMsg struct {
+		network, channel string
+		members          map[string]string
+	}
+	ircChannelErrorMsg struct{ network, channel, message string }
+	ircTopicMsg        struct {
+		network, channel, topic, setBy string
+		changed                        bool // Live TOPIC change rather than the reply on join
+	}
+	ircTopicWhoTimeMsg struct {
+		network, channel, setBy string
+		setAt                   time.Time
+	}
+	ircActionMsg struct{ network, user, channel, message string }
+	ircCTCPMsg   struct {
+		network, user, channel, command, args string
+		rateLimited                           bool // Our answer was held back by the flood limit
+	}
+	ircCTCPReplyMsg   struct{ network, user, command, args string }
+	ircClientReadyMsg struct {
+		network string
+		session *session.Session
+	}
 )
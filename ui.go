@@ -2,20 +2,29 @@ package main
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
+// View draws the screen, led by any bell or desktop notification not yet
+// taken off it. Bubble Tea drops tea.Println output on the alternate
+// screen, so alerts reach the terminal through the renderer this way.
 func (m model) View() string {
+	return m.pendingAlert + m.screen()
+}
+
+func (m model) screen() string {
 	if !m.ready {
 		return systemMessageStyle.Render("Initializing IRC client...")
 	}
 
 	if m.err != nil {
 		return fmt.Sprintf("%s\n\n%s",
-			formatErrorMessage(fmt.Sprintf("Error: %v", m.err)),
+			formatErrorMessage(fmt.Sprintf("Error: %v", m.err)).render(m.width, false),
 			helpStyle.Render("Press any key to quit."))
 	}
 
@@ -23,56 +32,91 @@ func (m model) View() string {
 		return m.renderSetupView()
 	}
 
+	net := m.currentNetwork()
 	var headerText string
-	if m.connected {
-		uptime := time.Since(m.connectionTime).Truncate(time.Second)
+	if net == nil {
+		headerText = "IRC Client - Disconnected"
+	} else if net.connected {
+		uptime := now().Sub(net.connectionTime).Truncate(time.Second)
+		channelName := ""
+		if channel, exists := m.channels[m.currentBuffer]; exists {
+			channelName = channel.name
+		}
 		headerText = fmt.Sprintf("IRC Client - %s @ %s (%s) - Connected for %v",
-			m.currentNick, m.currentChannel, m.config.IRC.Server, uptime)
-	} else if m.state == stateConnecting {
-		headerText = fmt.Sprintf("IRC Client - Connecting to %s...", m.config.IRC.Server)
+			net.nick, channelName, net.config.Server, uptime)
+	} else if net.reconnect.waiting {
+		headerText = fmt.Sprintf("IRC Client - Reconnecting to %s...", net.config.Server)
+	} else if net.connecting {
+		headerText = fmt.Sprintf("IRC Client - Connecting to %s...", net.config.Server)
 	} else {
 		headerText = "IRC Client - Disconnected"
 	}
-	header := headerStyle.Render(headerText)
+	header := oneLine(headerStyle, headerText+m.activityBar())
 
 	var statusText string
-	if m.connected {
-		joinedChannels := m.getJoinedChannels()
-		joinedChannelsList := strings.Join(joinedChannels, ", ")
+	if net == nil {
+		statusText = "Disconnected"
+	} else if net.connected {
+		joinedChannels := m.networkChannels(net.name)
+		names := make([]string, len(joinedChannels))
+		for i, key := range joinedChannels {
+			names[i] = m.channels[key].name
+		}
+		joinedChannelsList := strings.Join(names, ", ")
 		if joinedChannelsList == "" {
 			joinedChannelsList = "none"
 		}
-		statusText = fmt.Sprintf("Connected | Current: %s | Joined: [%s] | Tab/Shift+Tab/Alt+1-9 to switch", m.currentChannel, joinedChannelsList)
-	} else if m.state == stateConnecting {
+		current := m.bufferLabel(m.currentBuffer)
+		if len(m.networkOrder) > 1 {
+			current = fmt.Sprintf("%s | Networks: %d/%d", current, m.connectedCount(), len(m.networkOrder))
+		}
+		statusText = fmt.Sprintf("Connected | Current: %s | Joined: [%s] | Alt+Left/Right to switch", current, joinedChannelsList)
+	} else if net.reconnect.waiting {
+		statusText = m.reconnectStatus(net)
+	} else if net.connecting {
 		statusText = "Connecting to server..."
 	} else {
 		statusText = "Disconnected"
 	}
-	status := statusStyle.Render(statusText)
+	status := oneLine(statusStyle, statusText)
+
+	topic := topicBarStyle.Render(m.topicBarText())
 
-	chatContent := m.viewport.View()
-	chat := chatAreaStyle.Render(chatContent)
+	chatContent := m.newMessagesMarker(m.viewport.View())
+	var chat string
+	if m.nicklistVisible() {
+		chatStyle := chatAreaStyle.Width(chatAreaStyle.GetWidth() - nicklistWidth)
+		chat = chatStyle.Render(chatContent)
+		chat = lipgloss.JoinHorizontal(lipgloss.Top, chat, m.renderNicklist(lipgloss.Height(chat)))
+	} else {
+		chat = chatAreaStyle.Render(chatContent)
+	}
 
 	textareaView := m.textarea.View()
 	input := inputBoxFocusedStyle.Render(textareaView)
 
+	sidebarShown := m.sidebarShownWidth() > 0
 	var help string
-	if m.showSidebar {
-		help = helpStyle.Render("Commands: /help, /join #channel | Tab: next channel | Shift+Tab: prev channel | Ctrl+B: toggle sidebar | Ctrl+P: command palette | Ctrl+C: exit")
+	if m.search != nil {
+		help = oneLine(helpStyle, m.historySearchPrompt())
+	} else if m.formatChord {
+		help = oneLine(helpStyle, formatChordPrompt())
+	} else if sidebarShown {
+		help = helpStyle.Render(fitHints(helpStyle, "Commands: /help, /join #channel", "Ctrl+P: command palette", "Tab: complete", "Up/Ctrl+R: history", "Alt+Right/Left: next/prev channel", "Ctrl+B: toggle sidebar", "Ctrl+C: exit"))
 	} else {
-		help = helpStyle.Render("Commands: /help, /join #channel, /nick <name>, /quit | Tab: next channel | Shift+Tab: prev channel | Ctrl+B: show sidebar | Ctrl+P: command palette | Ctrl+C: exit")
+		help = helpStyle.Render(fitHints(helpStyle, "Commands: /help, /join #channel, /nick <name>, /quit", "Ctrl+P: command palette", "Tab: complete", "Up/Ctrl+R: history", "Alt+Right/Left: next/prev channel", "Ctrl+B: show sidebar", "Ctrl+C: exit"))
 	}
 
 	// Render command palette overlay if visible
 	baseView := ""
-	if m.showSidebar {
+	if sidebarShown {
 		sidebar := m.renderSidebar()
 		mainContent := lipgloss.JoinVertical(lipgloss.Left, chat, input, help)
 		contentArea := lipgloss.JoinHorizontal(lipgloss.Top, sidebar, mainContent)
-		baseView = lipgloss.JoinVertical(lipgloss.Left, header, status, contentArea)
+		baseView = lipgloss.JoinVertical(lipgloss.Left, header, topic, status, contentArea)
 	} else {
 		mainContent := lipgloss.JoinVertical(lipgloss.Left, chat, input, help)
-		baseView = lipgloss.JoinVertical(lipgloss.Left, header, status, mainContent)
+		baseView = lipgloss.JoinVertical(lipgloss.Left, header, topic, status, mainContent)
 	}
 
 	if m.commandPaletteVisible {
@@ -86,8 +130,66 @@ func (m model) View() string {
 	return baseView
 }
 
+// oneLine renders text in a style one line high, cutting what does not fit
+func oneLine(style lipgloss.Style, text string) string {
+	return style.Render(ansi.Truncate(text, style.GetWidth()-style.GetHorizontalPadding(), "…"))
+}
+
+// fitHints joins as many of the hints as fit on one line of a style,
+// leaving out the ones at the end first
+func fitHints(style lipgloss.Style, hints ...string) string {
+	width := style.GetWidth() - style.GetHorizontalPadding()
+	line := ""
+	for _, hint := range hints {
+		next := hint
+		if line != "" {
+			next = line + " | " + hint
+		}
+		if lipgloss.Width(next) > width {
+			break
+		}
+		line = next
+	}
+	return line
+}
+
+// topicBarText returns the single line shown under the header
+func (m model) topicBarText() string {
+	channel, exists := m.channels[m.currentBuffer]
+	switch {
+	case !exists:
+		return ""
+	case channel.isQuery:
+		return fmt.Sprintf("Private conversation with %s", channel.name)
+	}
+	topic := m.topic(channel)
+	if topic == "" {
+		return "No topic set"
+	}
+
+	// Keep the bar to one plain line; the full topic is available via /topic
+	topic = strings.Join(strings.Fields(stripFormatting(topic)), " ")
+	maxLen := m.width - 4
+	if maxLen > 3 && len([]rune(topic)) > maxLen {
+		topic = string([]rune(topic)[:maxLen-3]) + "..."
+	}
+	return topic
+}
+
+// setupPart is one block of the setup wizard. Optional blocks are left
+// out, lowest priority first, when the terminal is too short for them all.
+type setupPart struct {
+	view     string
+	priority int // 0 for the blocks always shown
+}
+
 func (m model) renderSetupView() string {
-	var content []string
+	var content []setupPart
+	add := func(priority int, views ...string) {
+		for _, view := range views {
+			content = append(content, setupPart{view, priority})
+		}
+	}
 
 	// Welcome section with improved spacing
 	if m.setupPhase == setupServer {
@@ -100,36 +202,34 @@ func (m model) renderSetupView() string {
 				"  - Multiple channel support\n" +
 				"  - SSL/TLS encryption\n" +
 				"  - Customizable interface")
-		content = append(content, welcomeBox)
-		content = append(content, "")
+		add(1, welcomeBox, "")
 	}
 
 	// Main header with better spacing
 	title := setupTitleStyle.Render("GoIRC Setup Wizard")
-	content = append(content, title)
-	content = append(content, setupSubtitleStyle.Render("Modern IRC Client Configuration"))
-	content = append(content, "") // Extra spacing after header
+	add(0, title)
+	add(0, setupSubtitleStyle.Render("Modern IRC Client Configuration"))
+	add(0, "") // Extra spacing after header
 
 	// Progress bar
 	progressBar := m.renderProgressBar()
-	content = append(content, progressBar)
-	content = append(content, "") // Extra spacing after progress
+	add(4, progressBar, "") // Extra spacing after progress
 
 	switch m.setupPhase {
 	case setupServer:
-		content = append(content, setupStepHeaderStyle.Render("Step 1/4: Server Configuration"))
-		content = append(content, setupDescStyle.Render("Connect to your favorite IRC server. We support both standard and SSL connections."))
+		add(0, setupStepHeaderStyle.Render("Step 1/4: Server Configuration"))
+		add(0, setupDescStyle.Render("Connect to your favorite IRC server. We support both standard and SSL connections."))
 
 		// Server input section with validation hints
 		serverLabel := setupLabelStyle.Render("IRC Server Address:")
-		content = append(content, serverLabel)
+		add(0, serverLabel)
 
 		// Show validation error if any
 		if m.setupValidationError != "" {
-			content = append(content, setupValidationStyle.Render("Warning: "+m.setupValidationError))
+			add(0, wrapTo(setupValidationStyle, m.width, "Warning: "+m.setupValidationError))
 		}
 
-		content = append(content, setupHintStyle.Render(fmt.Sprintf("Default: %s (press Enter to use default)", defaultServer)))
+		add(0, wrapTo(setupHintStyle, m.width, fmt.Sprintf("Default: %s (press Enter to use default)", defaultServer)))
 
 		// Examples box with more servers
 		exampleBox := setupExampleBoxStyle.Render(
@@ -140,11 +240,11 @@ func (m model) renderSetupView() string {
 				"   EFnet:          irc.efnet.org:6697 (SSL)\n" +
 				"   Freenode:       chat.freenode.net:6667\n\n" +
 				"Tip: Use port 6697 for SSL, 6667 for standard")
-		content = append(content, exampleBox)
+		add(2, exampleBox)
 
 	case setupNick:
-		content = append(content, setupStepHeaderStyle.Render("Step 2/4: Your Identity"))
-		content = append(content, setupDescStyle.Render("Choose a unique nickname that represents you on IRC. Make it memorable!"))
+		add(0, setupStepHeaderStyle.Render("Step 2/4: Your Identity"))
+		add(0, setupDescStyle.Render("Choose a unique nickname that represents you on IRC. Make it memorable!"))
 
 		// Server confirmation with SSL indicator
 		sslText := "Standard"
@@ -153,21 +253,21 @@ func (m model) renderSetupView() string {
 		}
 		serverInfo := setupInfoBoxStyle.Render(fmt.Sprintf("Server Configuration Complete\n\nServer: %s\nConnection: %s",
 			m.config.IRC.Server, sslText))
-		content = append(content, serverInfo)
+		add(3, serverInfo)
 
 		nickLabel := setupLabelStyle.Render("Your Nickname:")
-		content = append(content, nickLabel)
+		add(0, nickLabel)
 
 		if m.setupValidationError != "" {
-			content = append(content, setupValidationStyle.Render("Warning: "+m.setupValidationError))
+			add(0, wrapTo(setupValidationStyle, m.width, "Warning: "+m.setupValidationError))
 		}
 
-		content = append(content, setupHintStyle.Render(fmt.Sprintf("Default: %s (press Enter to use default)", defaultNick)))
-		content = append(content, setupHintStyle.Render("Tip: Choose 3-16 characters, letters and numbers only"))
+		add(0, wrapTo(setupHintStyle, m.width, fmt.Sprintf("Default: %s (press Enter to use default)", defaultNick)))
+		add(0, wrapTo(setupHintStyle, m.width, "Tip: Choose 3-16 characters, letters and numbers only"))
 
 	case setupChannels:
-		content = append(content, setupStepHeaderStyle.Render("Step 3/4: Join Channels"))
-		content = append(content, setupDescStyle.Render("Channels are where conversations happen. Join some to get started!"))
+		add(0, setupStepHeaderStyle.Render("Step 3/4: Join Channels"))
+		add(0, setupDescStyle.Render("Channels are where conversations happen. Join some to get started!"))
 
 		// Configuration summary
 		sslText := "Standard"
@@ -177,17 +277,17 @@ func (m model) renderSetupView() string {
 		configInfo := setupInfoBoxStyle.Render(fmt.Sprintf(
 			"Configuration Progress\n\nServer: %s\nConnection: %s\nNickname: %s",
 			m.config.IRC.Server, sslText, m.config.IRC.Nick))
-		content = append(content, configInfo)
+		add(3, configInfo)
 
 		channelLabel := setupLabelStyle.Render("Channels to Join:")
-		content = append(content, channelLabel)
+		add(0, channelLabel)
 
 		if m.setupValidationError != "" {
-			content = append(content, setupValidationStyle.Render("Warning: "+m.setupValidationError))
+			add(0, wrapTo(setupValidationStyle, m.width, "Warning: "+m.setupValidationError))
 		}
 
-		content = append(content, setupHintStyle.Render(fmt.Sprintf("Default: %s (press Enter to use default)", defaultChannel)))
-		content = append(content, setupHintStyle.Render("Tip: Separate multiple channels with commas (e.g., #general, #help, #dev)"))
+		add(0, wrapTo(setupHintStyle, m.width, fmt.Sprintf("Default: %s (press Enter to use default)", defaultChannel)))
+		add(0, wrapTo(setupHintStyle, m.width, "Tip: Separate multiple channels with commas (e.g., #general, #help, #dev)"))
 
 		// Popular channels example
 		exampleBox := setupExampleBoxStyle.Render(
@@ -196,11 +296,11 @@ func (m model) renderSetupView() string {
 				"   OFTC:        #debian, #tor, #spi\n" +
 				"   Rizon:       #news, #anime, #programming\n\n" +
 				"Tip: Channel names start with # (automatically added)")
-		content = append(content, exampleBox)
+		add(2, exampleBox)
 
 	case setupConfirm:
-		content = append(content, setupStepHeaderStyle.Render("Step 4/4: Ready to Connect"))
-		content = append(content, setupDescStyle.Render("Review your configuration and let's get you connected to IRC!"))
+		add(0, setupStepHeaderStyle.Render("Step 4/4: Ready to Connect"))
+		add(0, setupDescStyle.Render("Review your configuration and let's get you connected to IRC!"))
 
 		// Final configuration summary
 		sslText := "Standard Connection"
@@ -220,16 +320,16 @@ func (m model) renderSetupView() string {
 				fmt.Sprintf("Nickname:    %s\n", m.config.IRC.Nick) +
 				fmt.Sprintf("Channels:    %s\n\n", channelList) +
 				"Ready to connect and start chatting!")
-		content = append(content, summaryBox)
+		add(0, summaryBox)
 
 		actionHint := setupActionStyle.Render("Press Enter to connect • Type 'r' to restart setup • Ctrl+C to exit")
-		content = append(content, actionHint)
+		add(0, actionHint)
 	}
 
 	// Enhanced input box with better prompts
 	if m.setupPhase != setupConfirm {
 		inputBox := setupInputBoxStyle.Render(m.textarea.View())
-		content = append(content, inputBox)
+		add(0, inputBox)
 	}
 
 	// Footer with helpful controls
@@ -246,9 +346,47 @@ func (m model) renderSetupView() string {
 	}
 
 	footer := setupFooterStyle.Render(footerText)
-	content = append(content, footer)
+	add(0, footer)
 
-	return lipgloss.JoinVertical(lipgloss.Center, content...)
+	return m.fitSetupView(content)
+}
+
+// fitSetupView joins the wizard's blocks, leaving out optional ones until
+// it fits the terminal, and its top when even the rest do not
+func (m model) fitSetupView(parts []setupPart) string {
+	last := 0 // Priority of the last optional block to go
+	for _, part := range parts {
+		last = max(last, part.priority)
+	}
+
+	var view string
+	for level := 0; level <= last; level++ {
+		var views []string
+		for _, part := range parts {
+			if part.priority == 0 || part.priority > level {
+				views = append(views, part.view)
+			}
+		}
+		view = lipgloss.JoinVertical(lipgloss.Center, views...)
+		if lipgloss.Height(view) <= m.height {
+			break
+		}
+	}
+
+	lines := strings.Split(view, "\n")
+	if len(lines) > m.height {
+		lines = lines[len(lines)-m.height:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapTo renders text in a style, wrapping it only when it is wider
+// than the terminal
+func wrapTo(style lipgloss.Style, width int, text string) string {
+	if lipgloss.Width(text)+style.GetHorizontalFrameSize() > width {
+		style = style.Width(width - style.GetHorizontalBorderSize())
+	}
+	return style.Render(text)
 }
 
 func (m model) renderProgressBar() string {
@@ -326,33 +464,39 @@ func (m model) renderProgressBar() string {
 }
 
 func (m model) renderSidebar() string {
-	if !m.showSidebar {
+	width := m.sidebarShownWidth()
+	if width == 0 {
 		return ""
 	}
 
 	var content []string
-	sidebarHeight := m.height - headerHeight - footerHeight - statusHeight - 4 // Improved calculation
+	// The sidebar runs down beside the chat, input box and help line
+	sidebarHeight := m.height - headerHeight - statusHeight - topicHeight
 
 	// Sidebar header
 	content = append(content, sidebarHeaderStyle.Render("IRC CLIENT"))
 	content = append(content, "")
 
 	// Connection status with better spacing
+	connected := m.connectedCount()
 	statusText := ""
-	if m.connected {
+	if connected > 0 {
 		statusIcon := sidebarStatusDotStyle.Render("●")
 		statusText = fmt.Sprintf("%s CONNECTED", statusIcon)
 	} else {
 		statusIcon := sidebarDisconnectedDotStyle.Render("●")
 		statusText = fmt.Sprintf("%s DISCONNECTED", statusIcon)
 	}
+	if len(m.networkOrder) > 1 {
+		statusText += fmt.Sprintf(" %d/%d", connected, len(m.networkOrder))
+	}
 	content = append(content, sidebarSectionStyle.Render(statusText))
 	content = append(content, "")
 
 	// User and server info with better formatting
-	if m.connected && m.currentNick != "" {
-		content = append(content, sidebarItemStyle.Render(fmt.Sprintf("User: %s", m.currentNick)))
-		serverName := strings.Split(m.config.IRC.Server, ":")[0]
+	if net := m.currentNetwork(); net != nil && net.connected && net.nick != "" {
+		content = append(content, sidebarItemStyle.Render(fmt.Sprintf("User: %s", net.nick)))
+		serverName := strings.Split(net.config.Server, ":")[0]
 		if len(serverName) > 18 {
 			serverName = serverName[:15] + "..."
 		}
@@ -360,38 +504,78 @@ func (m model) renderSidebar() string {
 		content = append(content, "")
 	}
 
-	// Channels section with improved layout
-	joinedChannels := m.getJoinedChannels()
-	channelCountBadge := sidebarChannelCountStyle.Render(fmt.Sprintf(" %d ", len(joinedChannels)))
-	channelsHeader := fmt.Sprintf("CHANNELS %s", channelCountBadge)
-	content = append(content, sidebarSectionStyle.Render(channelsHeader))
+	// One section per network with its channels, then its queries;
+	// numbering runs on across networks in Tab order
+	number := 0
+	renderBuffer := func(key string) {
+		number++
+		channel := m.channels[key]
+		badges := activityBadges(channel)
+
+		// Truncate long channel names, leaving room for the badges
+		maxName := 20 - lipgloss.Width(badges)
+		if maxName < 8 {
+			maxName = 8
+		}
+		displayName := channel.name
+		if len(displayName) > maxName {
+			displayName = displayName[:maxName-3] + "..."
+		}
 
-	if len(joinedChannels) > 0 {
-		for i, channelName := range joinedChannels {
-			// Truncate long channel names
-			displayName := channelName
-			if len(displayName) > 20 {
-				displayName = displayName[:17] + "..."
-			}
+		channelLine := fmt.Sprintf("%d %s", number, displayName)
+
+		switch {
+		case key == m.currentBuffer:
+			content = append(content, sidebarActiveItemStyle.Render(fmt.Sprintf("> %s", channelLine)))
+		case channel.level == levelHighlight:
+			content = append(content, sidebarHighlightItemStyle.Render(fmt.Sprintf("  %s", channelLine))+badges)
+		case channel.level == levelMessage:
+			content = append(content, sidebarMessageItemStyle.Render(fmt.Sprintf("  %s", channelLine))+badges)
+		default:
+			content = append(content, sidebarItemStyle.Render(fmt.Sprintf("  %s", channelLine)))
+		}
+	}
+
+	for i, name := range m.networkOrder {
+		net := m.networks[name]
+		if i > 0 {
+			content = append(content, "")
+		}
 
-			channelLine := fmt.Sprintf("%d %s", i+1, displayName)
+		joinedChannels := m.networkChannels(name)
+		dot := sidebarDisconnectedDotStyle.Render("●")
+		if net.connected {
+			dot = sidebarStatusDotStyle.Render("●")
+		}
+		networkName := strings.ToUpper(name)
+		if len(networkName) > 16 {
+			networkName = networkName[:13] + "..."
+		}
+		channelCountBadge := sidebarChannelCountStyle.Render(fmt.Sprintf(" %d ", len(joinedChannels)))
+		content = append(content, sidebarSectionStyle.Render(fmt.Sprintf("%s %s %s", dot, networkName, channelCountBadge)))
 
-			if channelName == m.currentChannel {
-				content = append(content, sidebarActiveItemStyle.Render(fmt.Sprintf("> %s", channelLine)))
-			} else {
-				content = append(content, sidebarItemStyle.Render(fmt.Sprintf("  %s", channelLine)))
+		if len(joinedChannels) > 0 {
+			for _, key := range joinedChannels {
+				renderBuffer(key)
 			}
+		} else {
+			content = append(content, sidebarItemStyle.Render("  No channels joined"))
 		}
-	} else {
-		content = append(content, sidebarItemStyle.Render("  No channels joined"))
-	}
 
-	// Improved spacing and height management
-	maxLines := sidebarHeight - 2
-	if maxLines < 10 {
-		maxLines = 10 // Minimum height to prevent UI breaking
+		// Queries section, numbered after the channels
+		if queries := m.networkQueries(name); len(queries) > 0 {
+			content = append(content, sidebarItemStyle.Render(fmt.Sprintf("  Queries (%d)", len(queries))))
+			for _, key := range queries {
+				renderBuffer(key)
+			}
+		}
 	}
 
+	// Improved spacing and height management, counting the rows of
+	// entries that take more than one
+	content = strings.Split(strings.Join(content, "\n"), "\n")
+	maxLines := max(sidebarHeight-sidebarStyle.GetVerticalPadding(), 1)
+
 	if len(content) < maxLines {
 		for len(content) < maxLines {
 			content = append(content, "")
@@ -402,22 +586,50 @@ func (m model) renderSidebar() string {
 		content = append(content, sidebarItemStyle.Render("..."))
 	}
 
+	// Cut rather than wrap lines, which would push the sidebar taller
+	for i, line := range content {
+		content[i] = ansi.Truncate(line, width-sidebarStyle.GetHorizontalPadding(), "…")
+	}
+
 	sidebarContent := strings.Join(content, "\n")
 	return sidebarStyle.Height(sidebarHeight).Render(sidebarContent)
 }
 
-func (m model) renderCommandPalette() string {
-	const maxItems = 12
+func (m model) renderNicklist(height int) string {
+	members := m.channels[m.currentBuffer].nicklist
 
-	// Make palette width responsive to screen size
-	paletteWidth := 84
-	if m.width > 0 && paletteWidth > m.width-6 {
-		paletteWidth = m.width - 6
-	}
-	if paletteWidth < 60 {
-		paletteWidth = 60 // Minimum width
+	var content []string
+	content = append(content, nicklistHeaderStyle.Render(fmt.Sprintf("USERS %d", len(members))))
+
+	// Border and padding take two lines of the pane
+	maxLines := height - 2
+	for i, member := range members {
+		if len(content) >= maxLines-1 && i < len(members)-1 {
+			content = append(content, nicklistItemStyle.Render(fmt.Sprintf("+%d more", len(members)-i)))
+			break
+		}
+
+		displayName := member
+		if len(displayName) > nicklistWidth-4 {
+			displayName = displayName[:nicklistWidth-7] + "..."
+		}
+
+		style := nicklistItemStyle
+		if member[0] != ' ' {
+			style = nicklistPrivilegedStyle
+		}
+		content = append(content, style.Render(displayName))
 	}
 
+	return nicklistStyle.Height(height - 2).Render(strings.Join(content, "\n"))
+}
+
+// maxPaletteListHeight is the most rows of commands the palette lists
+const maxPaletteListHeight = 20
+
+func (m model) renderCommandPalette() string {
+	paletteWidth := commandPaletteBorderStyle.GetWidth()
+
 	var content []string
 
 	// Enhanced header with gradient-like effect and better styling
@@ -453,130 +665,95 @@ func (m model) renderCommandPalette() string {
 		content = append(content, commandPaletteSeparatorStyle.Render("--- Available Commands ---"))
 	}
 
-	// Items with enhanced display and better visual hierarchy
-	displayItems := m.commandPaletteFiltered
-	displaySelectionIndex := m.commandPaletteSelected
-
-	if len(displayItems) > maxItems {
-		// Show items around the selected one with scroll indicators
-		start := m.commandPaletteSelected - maxItems/2
-		if start < 0 {
-			start = 0
-		}
-		end := start + maxItems
-		if end > len(displayItems) {
-			end = len(displayItems)
-			start = end - maxItems
-			if start < 0 {
-				start = 0
-			}
-		}
-
-		// Add scroll indicators
-		if start > 0 {
-			content = append(content, commandPaletteItemStyle.Render("    ^  More commands above..."))
-		}
-
-		displayItems = displayItems[start:end]
-		displaySelectionIndex = m.commandPaletteSelected - start
-
-		if end < len(m.commandPaletteFiltered) {
-			// We'll add the bottom indicator after the items
-		}
+	// Footer with improved text
+	var footerText string
+	if len(m.commandPaletteFiltered) > 0 {
+		selectedNum := m.commandPaletteSelected + 1
+		totalNum := len(m.commandPaletteFiltered)
+		footerText = fmt.Sprintf("↑↓ Navigate (%d/%d) • Enter Execute • Esc Close • Ctrl+P Toggle", selectedNum, totalNum)
+	} else {
+		footerText = "Start typing to search commands • Esc Close"
 	}
-
-	// Track current category for grouping
+	footer := commandPaletteFooterStyle.Render(footerText)
+
+	// The list gets the rows the rest of the palette leaves on screen
+	listHeight := m.height - commandPaletteBorderStyle.GetVerticalFrameSize() -
+		lipgloss.Height(strings.Join(content, "\n")) - lipgloss.Height(footer)
+	listHeight = max(min(listHeight, maxPaletteListHeight), 3)
+
+	// Calculate responsive widths based on palette width
+	textWidth := commandPaletteItemStyle.GetWidth() - commandPaletteItemStyle.GetHorizontalPadding()
+	nameWidth := max(paletteWidth/3, 20)
+	shortcutWidth := 10
+	descWidth := max(textWidth-nameWidth-shortcutWidth-4, 8)
+
+	// Items grouped under their categories (only when not searching)
+	var list []string
+	selectedLine := 0
 	var lastCategory string
-	itemCount := 0
-
-	for i, item := range displayItems {
-		// Add category header (only when not searching) with better spacing
+	for i, item := range m.commandPaletteFiltered {
 		if m.commandPaletteQuery == "" && item.category != lastCategory {
 			if lastCategory != "" {
-				content = append(content, "") // Add spacing between categories
+				list = append(list, "") // Add spacing between categories
 			}
 
 			categoryText := fmt.Sprintf("-- %s", item.category)
-			content = append(content, commandPaletteCategoryStyle.Render(categoryText))
+			list = append(list, commandPaletteCategoryStyle.Render(categoryText))
 			lastCategory = item.category
 		}
 
 		// Item styling
-		var style lipgloss.Style
+		style := commandPaletteItemStyle
 		selectionIndicator := "  "
-
-		if i == displaySelectionIndex {
+		if i == m.commandPaletteSelected {
 			style = commandPaletteSelectedStyle
 			selectionIndicator = "> "
-		} else {
-			style = commandPaletteItemStyle
-		}
-
-		// Item display with responsive layout
-		iconAndName := fmt.Sprintf("%s%s %s", selectionIndicator, item.icon, item.name)
-
-		// Calculate responsive widths based on palette width
-		nameWidth := paletteWidth / 3
-		if nameWidth < 20 {
-			nameWidth = 20
+			selectedLine = len(list)
 		}
-		shortcutWidth := 15
-		descWidth := paletteWidth - nameWidth - shortcutWidth - 8
 
 		// Smart truncation with ellipsis
-		if len(iconAndName) > nameWidth {
-			iconAndName = iconAndName[:nameWidth-3] + "..."
-		}
-
-		description := item.description
-		if len(description) > descWidth {
-			description = description[:descWidth-3] + "..."
-		}
-
-		shortcut := ""
-		if item.shortcut != "" {
-			shortcut = item.shortcut
-		}
+		iconAndName := ansi.Truncate(fmt.Sprintf("%s%s %s", selectionIndicator, item.icon, item.name), nameWidth, "...")
+		description := ansi.Truncate(item.description, descWidth, "...")
 
 		// Create visually appealing item line with proper spacing
 		itemLine := fmt.Sprintf("%-*s  %-*s  %s",
 			nameWidth, iconAndName,
 			descWidth, description,
-			shortcut)
+			item.shortcut)
 
-		content = append(content, style.Render(itemLine))
-		itemCount++
+		list = append(list, style.Render(ansi.Truncate(itemLine, textWidth, "")))
 	}
 
-	// Add scroll indicator for bottom if needed
-	if len(displayItems) == maxItems && m.commandPaletteSelected+1 < len(m.commandPaletteFiltered) {
-		content = append(content, commandPaletteItemStyle.Render("    v  More commands below..."))
+	// Show "no results" message with better spacing
+	if len(m.commandPaletteFiltered) == 0 && m.commandPaletteQuery != "" {
+		list = append(list, "")
+		list = append(list, commandPaletteEmptyStyle.Render("No commands found"))
+		list = append(list, commandPaletteEmptyStyle.Render(fmt.Sprintf("No matches for \"%s\"", m.commandPaletteQuery)))
+		list = append(list, commandPaletteEmptyStyle.Render("Try different keywords or clear search"))
+		list = strings.Split(strings.Join(list, "\n"), "\n")
 	}
 
-	// Show "no results" message with better spacing
-	if len(displayItems) == 0 && m.commandPaletteQuery != "" {
-		content = append(content, "")
-		content = append(content, commandPaletteEmptyStyle.Render("No commands found"))
-		content = append(content, commandPaletteEmptyStyle.Render(fmt.Sprintf("No matches for \"%s\"", m.commandPaletteQuery)))
-		content = append(content, commandPaletteEmptyStyle.Render("Try different keywords or clear search"))
+	if len(list) > listHeight {
+		// Show the lines around the selected item with scroll indicators
+		start := max(min(selectedLine-listHeight/2, len(list)-listHeight), 0)
+		end := start + listHeight
+		more := end < len(list)
+		list = slices.Clone(list[start:end])
+		if start > 0 {
+			list[0] = commandPaletteItemStyle.Render("    ^  More commands above...")
+		}
+		if more {
+			list[len(list)-1] = commandPaletteItemStyle.Render("    v  More commands below...")
+		}
 	}
 
 	// Fill empty space to maintain consistent height
-	minHeight := maxItems + 8
-	for len(content) < minHeight {
-		content = append(content, "")
+	for len(list) < listHeight {
+		list = append(list, "")
 	}
 
-	// Footer with improved text
-	var footerText string
-	if len(m.commandPaletteFiltered) > 0 {
-		selectedNum := m.commandPaletteSelected + 1
-		totalNum := len(m.commandPaletteFiltered)
-		footerText = fmt.Sprintf("↑↓ Navigate (%d/%d) • Enter Execute • Esc Close • Ctrl+P Toggle", selectedNum, totalNum)
-	} else {
-		footerText = "Start typing to search commands • Esc Close"
-	}
-	content = append(content, commandPaletteFooterStyle.Render(footerText))
+	content = append(content, list...)
+	content = append(content, footer)
 
-	return commandPaletteBorderStyle.Width(paletteWidth).Render(strings.Join(content, "\n"))
+	return commandPaletteBorderStyle.Render(strings.Join(content, "\n"))
 }
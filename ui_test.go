@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenTime is the frozen clock every snapshot is drawn at
+var goldenTime = time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+
+// newGoldenModel is a model drawn without colors at a fixed size and time,
+// with no config or history of its own
+func newGoldenModel(t *testing.T, width, height int) model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	clock := now
+	now = func() time.Time { return goldenTime }
+	profile := lipgloss.ColorProfile()
+	dark := lipgloss.HasDarkBackground()
+	lipgloss.SetColorProfile(termenv.Ascii)
+	lipgloss.SetHasDarkBackground(true)
+	t.Cleanup(func() {
+		now = clock
+		lipgloss.SetColorProfile(profile)
+		lipgloss.SetHasDarkBackground(dark)
+	})
+
+	m := initialModel()
+	t.Cleanup(m.events.close)
+	return apply(t, m, tea.WindowSizeMsg{Width: width, Height: height})
+}
+
+// apply runs msgs through Update in order. Commands are dropped: the
+// snapshots show the screen as each message leaves it.
+func apply(t *testing.T, m model, msgs ...tea.Msg) model {
+	t.Helper()
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+// typeLine enters text in the input box and presses Enter
+func typeLine(t *testing.T, m model, text string) model {
+	t.Helper()
+	m.textarea.SetValue(text)
+	return apply(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+}
+
+// connectedModel is a client on the fake server in a channel with a
+// topic, a few members and some conversation
+func connectedModel(t *testing.T, width, height int, channel string) model {
+	t.Helper()
+	srv := newFakeServer(t, nil)
+	c := runClient(t, newGoldenModel(t, width, height), srv, false, channel)
+	conn := srv.accept()
+	conn.register()
+	c.waitForConnect()
+	conn.acceptJoin(channel, "Go programming | https://go.dev | Be nice", "@rob", "+ken", "gopher", "alice")
+	conn.from("alice", "PRIVMSG %s :has anyone tried the new iterator functions?", channel)
+	conn.from("rob", "PRIVMSG %s :yes, range over func is lovely once it clicks", channel)
+	conn.from("gopher", "PRIVMSG %s :\x01ACTION digs a tunnel\x01", channel)
+	conn.from("bob", "JOIN %s", channel)
+	conn.from("ken", "PRIVMSG %s :tester: see the release notes for the details, they cover the corner cases well", channel)
+	c.waitForLine(channel, "they cover the corner cases well")
+	// The last line mentions us; snapshot once its bell is off the screen
+	c.waitFor("the highlight's alert to end", func() bool { return c.m.pendingAlert == "" })
+	return c.m
+}
+
+// checkGolden compares a model's screen against testdata/<name>.golden,
+// rewriting the file instead when -update is given. Either way the screen
+// must fit the terminal the model was sized for.
+func checkGolden(t *testing.T, name string, m model) {
+	t.Helper()
+	got := m.View()
+	checkFits(t, name, got, m.width, m.height)
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from %s (run go test -update if the change is intended)\n%s", name, path, lineDiff(string(want), got))
+	}
+}
+
+// checkFits fails when a screen is wider or taller than the terminal
+func checkFits(t *testing.T, name, screen string, width, height int) {
+	t.Helper()
+	lines := strings.Split(screen, "\n")
+	if len(lines) > height {
+		t.Errorf("%s is %d rows, taller than the %d-row terminal", name, len(lines), height)
+	}
+	for i, line := range lines {
+		if w := lipgloss.Width(line); w > width {
+			t.Errorf("%s line %d is %d columns, wider than the %d-column terminal: %q", name, i+1, w, width, line)
+		}
+	}
+}
+
+// lineDiff lists the lines that differ between two screens
+func lineDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&b, "line %d:\n  want: %q\n   got: %q\n", i+1, w, g)
+		}
+	}
+	return b.String()
+}
+
+func TestSetupGolden(t *testing.T) {
+	m := newGoldenModel(t, 100, 50)
+	m.config.FilePath = filepath.Join(t.TempDir(), "config.json")
+	checkGolden(t, "setup_server", m)
+
+	m = typeLine(t, m, "irc.example.net:6697")
+	checkGolden(t, "setup_nick", m)
+
+	m = typeLine(t, m, "tester")
+	checkGolden(t, "setup_channels", m)
+
+	m = typeLine(t, m, "#golang, #go-nuts")
+	checkGolden(t, "setup_confirm", m)
+}
+
+func TestSetupValidationGolden(t *testing.T) {
+	m := newGoldenModel(t, 100, 50)
+	m.config.FilePath = filepath.Join(t.TempDir(), "config.json")
+	m = typeLine(t, m, "not a server!")
+	checkGolden(t, "setup_server_invalid", m)
+}
+
+func TestConnectedGolden(t *testing.T) {
+	m := connectedModel(t, 120, 30, "#golang")
+	checkGolden(t, "connected", m)
+
+	m = apply(t, m, tea.KeyMsg{Type: tea.KeyCtrlB})
+	checkGolden(t, "connected_no_sidebar", m)
+}
+
+func TestCommandPaletteGolden(t *testing.T) {
+	m := connectedModel(t, 120, 30, "#golang")
+	m = apply(t, m, tea.KeyMsg{Type: tea.KeyCtrlP})
+	checkGolden(t, "palette", m)
+
+	m = apply(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("join")})
+	checkGolden(t, "palette_filtered", m)
+}
+
+func TestNarrowGolden(t *testing.T) {
+	m := connectedModel(t, 60, 20, "#golang")
+	checkGolden(t, "narrow", m)
+
+	setup := newGoldenModel(t, 60, 40)
+	checkGolden(t, "narrow_setup", setup)
+}
+
+func TestLongChannelNameGolden(t *testing.T) {
+	m := connectedModel(t, 100, 24, "#an-extraordinarily-long-channel-name-for-testing-truncation")
+	checkGolden(t, "long_channel", m)
+}
+
+// A shortcut in the palette runs exactly one of its commands
+func TestPaletteShortcutsUnique(t *testing.T) {
+	seen := make(map[string]string)
+	for _, item := range initCommandPaletteItems() {
+		if item.shortcut == "" {
+			continue
+		}
+		if other, ok := seen[item.shortcut]; ok {
+			t.Errorf("%s is the shortcut for both %q and %q", item.shortcut, other, item.name)
+		}
+		seen[item.shortcut] = item.name
+	}
+}
+
+// A highlight's bell is drawn ahead of the next frames, not written past
+// the renderer, and comes off the screen once its time is up
+func TestAlertDrawnWithFrame(t *testing.T) {
+	m := connectedModel(t, 120, 30, "#golang")
+	m = apply(t, m, ircPrivmsgMsg{network: m.networkOrder[0], user: "alice", message: "tester: ping", channel: "#golang"})
+	if !strings.HasPrefix(m.View(), "\a") {
+		t.Fatalf("highlight drew %q, want a bell ahead of the screen", m.View()[:10])
+	}
+	checkFits(t, "alert", m.View(), m.width, m.height)
+
+	stale := alertDoneMsg{gen: m.alertGen - 1}
+	if m = apply(t, m, stale); !strings.HasPrefix(m.View(), "\a") {
+		t.Error("an earlier alert's tick took the bell off the screen")
+	}
+	if m = apply(t, m, alertDoneMsg{gen: m.alertGen}); strings.Contains(m.View(), "\a") {
+		t.Error("bell still drawn after its tick")
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -12,65 +13,125 @@ func max(a, b int) int {
 	return b
 }
 
-func formatTimestamp() string {
-	return timestampStyle.Render(time.Now().Format("15:04"))
+func formatUserMessage(user, message string) Message {
+	return newMessage(KindChat, user, "", message)
 }
 
-func formatMessage(timestamp, content string) string {
-	return fmt.Sprintf("%s %s", timestamp, content)
+func formatOwnMessage(nick, message string) Message {
+	return newMessage(KindOwn, nick, "", message)
 }
 
-func formatUserMessage(user, message string) string {
-	timestamp := formatTimestamp()
+func formatUserMessageWithContext(user, message, currentNick string) Message {
+	if user == currentNick {
+		return newMessage(KindOwn, user, "", message)
+	}
+	return newMessage(KindChat, user, "", message)
+}
 
-	return formatMessage(timestamp, userMessageStyle.Render(fmt.Sprintf("<%s> %s", user, message)))
+func formatSystemMessage(message string) Message {
+	return newMessage(KindSystem, "", "", message)
 }
 
-func formatUserMessageWithContext(user, message, currentNick string) string {
-	timestamp := formatTimestamp()
-	if user == currentNick {
-		return formatMessage(timestamp, ownMessageStyle.Render(fmt.Sprintf("<%s> %s", user, message)))
+func formatJoinMessage(user, channel string) Message {
+	return newMessage(KindJoin, user, channel, fmt.Sprintf("+ %s joined %s", user, channel))
+}
+
+func formatPartMessage(user, channel, reason string) Message {
+	if reason != "" {
+		return newMessage(KindPart, user, channel, fmt.Sprintf("- %s left %s (%s)", user, channel, reason))
+	}
+	return newMessage(KindPart, user, channel, fmt.Sprintf("- %s left %s", user, channel))
+}
+
+func formatQuitMessage(user, reason string) Message {
+	if reason != "" {
+		return newMessage(KindQuit, user, "", fmt.Sprintf("< %s quit (%s)", user, reason))
+	}
+	return newMessage(KindQuit, user, "", fmt.Sprintf("< %s quit", user))
+}
+
+// formatNetsplitMessage sums up the nicks a channel lost in a netsplit, or
+// got back when it healed
+func formatNetsplitMessage(servers string, nicks []string, rejoin bool) Message {
+	shown := nicks
+	more := ""
+	if len(shown) > netsplitShownNicks {
+		shown = shown[:netsplitShownNicks]
+		more = fmt.Sprintf(" (+%d more)", len(nicks)-netsplitShownNicks)
 	}
-	return formatMessage(timestamp, userMessageStyle.Render(fmt.Sprintf("<%s> %s", user, message)))
+	list := strings.Join(shown, ", ") + more
+	if rejoin {
+		return newMessage(KindJoin, "", "", fmt.Sprintf("+ Netsplit over %s, %d rejoined: %s", servers, len(nicks), list))
+	}
+	return newMessage(KindQuit, "", "", fmt.Sprintf("< Netsplit %s, %d quit: %s", servers, len(nicks), list))
 }
 
-func formatSystemMessage(message string) string {
-	timestamp := formatTimestamp()
-	return formatMessage(timestamp, systemMessageStyle.Render(message))
+func formatNoticeMessage(from, message string) Message {
+	return newMessage(KindNotice, from, "", message)
 }
 
-func formatJoinMessage(user, channel string) string {
-	timestamp := formatTimestamp()
-	return formatMessage(timestamp, joinMessageStyle.Render(fmt.Sprintf("+ %s joined %s", user, channel)))
+func formatErrorMessage(message string) Message {
+	return newMessage(KindError, "", "", message)
 }
 
-func formatPartMessage(user, channel, reason string) string {
-	timestamp := formatTimestamp()
-	if reason != "" {
-		return formatMessage(timestamp, partMessageStyle.Render(fmt.Sprintf("- %s left %s (%s)", user, channel, reason)))
+func formatChannelSwitchMessage(message string) Message {
+	return newMessage(KindSwitch, "", "", message)
+}
+
+func formatTopicMessage(channel, topic string) Message {
+	if topic == "" {
+		return newMessage(KindTopic, "", channel, fmt.Sprintf("* No topic set for %s", channel))
+	}
+	return newMessage(KindTopic, "", channel, fmt.Sprintf("* Topic for %s: %s", channel, topic))
+}
+
+func formatTopicSetByMessage(setBy string, setAt time.Time) Message {
+	if setAt.IsZero() {
+		return newMessage(KindTopic, setBy, "", fmt.Sprintf("* Topic set by %s", setBy))
+	}
+	return newMessage(KindTopic, setBy, "", fmt.Sprintf("* Topic set by %s on %s", setBy, setAt.Format("2006-01-02 15:04")))
+}
+
+func formatTopicChangeMessage(user, channel, topic string) Message {
+	if topic == "" {
+		return newMessage(KindTopic, user, channel, fmt.Sprintf("* %s cleared the topic of %s", user, channel))
 	}
-	return formatMessage(timestamp, partMessageStyle.Render(fmt.Sprintf("- %s left %s", user, channel)))
+	return newMessage(KindTopic, user, channel, fmt.Sprintf("* %s changed the topic of %s to: %s", user, channel, topic))
 }
 
-func formatQuitMessage(user, reason string) string {
-	timestamp := formatTimestamp()
+func formatActionMessage(user, action string) Message {
+	return newMessage(KindAction, user, "", action)
+}
+
+func formatCTCPRequestMessage(user, command string) Message {
+	return newMessage(KindCTCP, user, "", fmt.Sprintf("CTCP %s from %s", command, user))
+}
+
+func formatCTCPReplyMessage(user, command, reply string) Message {
+	return newMessage(KindCTCP, user, "", fmt.Sprintf("CTCP %s reply from %s: %s", command, user, reply))
+}
+
+func formatKickMessage(kicker, channel, target, reason string) Message {
 	if reason != "" {
-		return formatMessage(timestamp, quitMessageStyle.Render(fmt.Sprintf("< %s quit (%s)", user, reason)))
+		return newMessage(KindKick, kicker, channel, fmt.Sprintf("! %s kicked %s from %s (%s)", kicker, target, channel, reason))
 	}
-	return formatMessage(timestamp, quitMessageStyle.Render(fmt.Sprintf("< %s quit", user)))
+	return newMessage(KindKick, kicker, channel, fmt.Sprintf("! %s kicked %s from %s", kicker, target, channel))
 }
 
-func formatNoticeMessage(from, message string) string {
-	timestamp := formatTimestamp()
-	return formatMessage(timestamp, noticeMessageStyle.Render(fmt.Sprintf("[%s] %s", from, message)))
+func formatModeMessage(setter, channel, modes string) Message {
+	return newMessage(KindMode, setter, channel, fmt.Sprintf("* %s sets mode %s on %s", setter, modes, channel))
 }
 
-func formatErrorMessage(message string) string {
-	timestamp := formatTimestamp()
-	return formatMessage(timestamp, errorMessageStyle.Render(fmt.Sprintf("! %s", message)))
+func formatBanMessage(setter, channel, mask string, adding bool) Message {
+	if adding {
+		return newMessage(KindBan, setter, channel, fmt.Sprintf("! %s bans %s from %s", setter, mask, channel))
+	}
+	return newMessage(KindMode, setter, channel, fmt.Sprintf("* %s unbans %s from %s", setter, mask, channel))
 }
 
-func formatChannelSwitchMessage(message string) string {
-	timestamp := formatTimestamp()
-	return formatMessage(timestamp, channelSwitchStyle.Render(message))
+func formatUserModeMessage(setter, nick, modes string) Message {
+	if setter == "" || setter == nick {
+		return newMessage(KindMode, setter, nick, fmt.Sprintf("* User mode for %s: %s", nick, modes))
+	}
+	return newMessage(KindMode, setter, nick, fmt.Sprintf("* %s sets user mode %s on %s", setter, modes, nick))
 }